@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type AccountType string
+
+const (
+	Checking   AccountType = "checking"
+	Savings    AccountType = "savings"
+	CreditCard AccountType = "credit-card"
+	Loan       AccountType = "loan"
+	Investment AccountType = "investment"
+	Cash       AccountType = "cash"
+)
+
+var accountTypes = []AccountType{Checking, Savings, CreditCard, Loan, Investment, Cash}
+
+// IsLiability reports whether a positive balance on this type of account is
+// money owed rather than money held.
+func (t AccountType) IsLiability() bool {
+	return t == CreditCard || t == Loan
+}
+
+// SignConvention says how an account's export signs its amounts.
+type SignConvention string
+
+const (
+	// BankSigns means expenses are negative and income positive, which is how
+	// transactions are stored.
+	BankSigns SignConvention = "bank"
+	// InvertedSigns means charges are positive and payments negative, as most
+	// credit card exports do.
+	InvertedSigns SignConvention = "inverted"
+)
+
+// defaultSignConvention returns the sign convention exports for this type of
+// account usually use.
+func (t AccountType) defaultSignConvention() SignConvention {
+	if t == CreditCard {
+		return InvertedSigns
+	}
+	return BankSigns
+}
+
+type Account struct {
+	Name           string
+	Institution    string
+	Type           AccountType
+	Currency       string
+	OpeningBalance float64
+	// File is the CSV export the account's transactions are imported from.
+	File string
+	// Signs overrides the sign convention for the account's type.
+	Signs SignConvention
+}
+
+type Accounts []Account
+
+// loadAccounts reads account definitions from a CSV file with the header
+// name,institution,type,currency,opening_balance,file,signs. Relative file
+// paths are resolved against the accounts file's directory.
+func loadAccounts(filename string) (Accounts, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts Accounts
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "name") {
+			continue
+		}
+		if len(record) < 4 {
+			return nil, fmt.Errorf("%s line %d: expected at least name, institution, type and currency", filename, i+1)
+		}
+
+		account := Account{
+			Name:        strings.TrimSpace(record[0]),
+			Institution: strings.TrimSpace(record[1]),
+			Type:        AccountType(strings.ToLower(strings.TrimSpace(record[2]))),
+			Currency:    strings.ToUpper(strings.TrimSpace(record[3])),
+		}
+
+		if !account.Type.valid() {
+			return nil, fmt.Errorf("%s line %d: unknown account type %q", filename, i+1, record[2])
+		}
+
+		if len(record) > 4 && strings.TrimSpace(record[4]) != "" {
+			account.OpeningBalance, err = strconv.ParseFloat(strings.TrimSpace(record[4]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: invalid opening balance: %w", filename, i+1, err)
+			}
+		}
+
+		if len(record) > 5 && strings.TrimSpace(record[5]) != "" {
+			account.File = strings.TrimSpace(record[5])
+			if !filepath.IsAbs(account.File) {
+				account.File = filepath.Join(filepath.Dir(filename), account.File)
+			}
+		}
+
+		account.Signs = account.Type.defaultSignConvention()
+		if len(record) > 6 && strings.TrimSpace(record[6]) != "" {
+			account.Signs = SignConvention(strings.ToLower(strings.TrimSpace(record[6])))
+			if account.Signs != BankSigns && account.Signs != InvertedSigns {
+				return nil, fmt.Errorf("%s line %d: signs must be %q or %q", filename, i+1, BankSigns, InvertedSigns)
+			}
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+func (t AccountType) valid() bool {
+	for _, accountType := range accountTypes {
+		if t == accountType {
+			return true
+		}
+	}
+	return false
+}
+
+// importAccount imports an account's CSV export, linking every transaction to
+// the account and normalising its signs so expenses are negative.
+func importAccount(account Account, filename string, profile ImportProfile) (Transactions, error) {
+	transactions, err := importCSV(filename, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range transactions {
+		transaction := &transactions[i]
+		transaction.Account = account.Name
+
+		if account.Signs == InvertedSigns {
+			transaction.Amount = -transaction.Amount
+			transaction.Balance = -transaction.Balance
+			transaction.Type = transactionType(transaction.Amount)
+		}
+	}
+
+	return transactions, nil
+}
+
+// account returns the account with the given name.
+func (app *application) account(name string) (Account, bool) {
+	for _, account := range app.accounts {
+		if strings.EqualFold(account.Name, name) {
+			return account, true
+		}
+	}
+	return Account{}, false
+}
+
+// accountNames returns the names of the accounts the loaded transactions
+// belong to, in the order they were defined.
+func (app *application) accountNames() []string {
+	seen := make(map[string]bool)
+	for _, transaction := range *app.transactions {
+		seen[transaction.Account] = true
+	}
+
+	var names []string
+	for _, account := range app.accounts {
+		if seen[account.Name] {
+			names = append(names, account.Name)
+			delete(seen, account.Name)
+		}
+	}
+
+	// Transactions imported with -a but no accounts file
+	var rest []string
+	for name := range seen {
+		rest = append(rest, name)
+	}
+	sort.Strings(rest)
+
+	return append(names, rest...)
+}
+
+// filterAccounts returns the transactions belonging to any of the accounts in
+// a "|" separated list of names.
+func (app *application) filterAccounts(names string) Transactions {
+	var filtered Transactions
+	for _, transaction := range *app.transactions {
+		for _, name := range strings.Split(names, "|") {
+			if strings.EqualFold(transaction.Account, strings.TrimSpace(name)) {
+				filtered = append(filtered, transaction)
+				break
+			}
+		}
+	}
+
+	return filtered
+}
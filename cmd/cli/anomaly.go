@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Severity int
+
+const (
+	Low Severity = iota
+	Medium
+	High
+)
+
+func (s Severity) String() string {
+	switch s {
+	case High:
+		return "HIGH"
+	case Medium:
+		return "MEDIUM"
+	}
+	return "LOW"
+}
+
+func parseSeverity(name string) (Severity, error) {
+	switch strings.ToLower(name) {
+	case "low":
+		return Low, nil
+	case "medium":
+		return Medium, nil
+	case "high":
+		return High, nil
+	}
+	return Low, fmt.Errorf("unknown severity %q: use low, medium or high", name)
+}
+
+type Anomaly struct {
+	Transaction Transaction
+	Reason      string
+	Severity    Severity
+}
+
+// AnomalyOptions controls how unusual something has to be before it is flagged.
+type AnomalyOptions struct {
+	// Sensitivity is the number of standard deviations above normal at which
+	// an amount is flagged. Lower values flag more.
+	Sensitivity float64
+	// NewPayeeThreshold is the amount above which a first payment to a payee
+	// is flagged.
+	NewPayeeThreshold float64
+	// MinHistory is the number of earlier payments or months needed before
+	// an amount is compared against them.
+	MinHistory int
+}
+
+// meanStdDev returns the mean and population standard deviation of values.
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+
+	var variance float64
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// deviationSeverity grades how far a value is above normal. ok is false when
+// it is not far enough above to flag.
+func deviationSeverity(value, mean, stdDev, sensitivity float64) (deviations float64, severity Severity, ok bool) {
+	if value <= mean {
+		return 0, Low, false
+	}
+
+	// With no spread at all, treat doubling the usual amount as one
+	// sensitivity's worth of deviation.
+	if stdDev == 0 {
+		if mean == 0 {
+			return 0, Low, false
+		}
+		deviations = (value - mean) / mean * sensitivity
+	} else {
+		deviations = (value - mean) / stdDev
+	}
+
+	switch {
+	case deviations >= 2*sensitivity:
+		return deviations, High, true
+	case deviations >= 1.5*sensitivity:
+		return deviations, Medium, true
+	case deviations >= sensitivity:
+		return deviations, Low, true
+	}
+
+	return deviations, Low, false
+}
+
+// aboveNormal describes how far a value is above normal, as standard
+// deviations or, with no spread to measure them by, as a multiple of the mean.
+// usual names the mean, such as "the usual".
+func aboveNormal(value, mean, stdDev, deviations float64, usual string) string {
+	if stdDev == 0 {
+		return fmt.Sprintf("%.1f× %s $%.2f", value/mean, usual, mean)
+	}
+	return fmt.Sprintf("%.1f standard deviations above %s $%.2f", deviations, usual, mean)
+}
+
+// findPayeeOutliers flags expenses far above what is usually paid to the same
+// payee, compared against every other payment to them.
+func findPayeeOutliers(expenses Transactions, options AnomalyOptions) []Anomaly {
+	byPayee := make(map[string]Transactions)
+	for _, transaction := range expenses {
+		byPayee[transaction.Description] = append(byPayee[transaction.Description], transaction)
+	}
+
+	var anomalies []Anomaly
+	for _, payments := range byPayee {
+		if len(payments)-1 < options.MinHistory {
+			continue
+		}
+
+		for i, transaction := range payments {
+			var others []float64
+			for j, other := range payments {
+				if i != j {
+					others = append(others, math.Abs(other.Amount))
+				}
+			}
+
+			mean, stdDev := meanStdDev(others)
+			deviations, severity, ok := deviationSeverity(math.Abs(transaction.Amount), mean, stdDev, options.Sensitivity)
+			if !ok {
+				continue
+			}
+
+			anomalies = append(anomalies, Anomaly{
+				Transaction: transaction,
+				Reason:      aboveNormal(math.Abs(transaction.Amount), mean, stdDev, deviations, "the usual") + " paid to this payee",
+				Severity:    severity,
+			})
+		}
+	}
+
+	return anomalies
+}
+
+// findCategorySpikes flags months where a category's spend is far above its
+// earlier months. The largest expense of the month stands in for the spike.
+func findCategorySpikes(expenses Transactions, options AnomalyOptions) []Anomaly {
+	type categoryMonth struct {
+		total   float64
+		largest Transaction
+	}
+
+	months := make(map[string]map[time.Time]*categoryMonth)
+	for _, transaction := range expenses {
+		if months[transaction.Category] == nil {
+			months[transaction.Category] = make(map[time.Time]*categoryMonth)
+		}
+
+		month := Monthly.start(transaction.Date)
+		totals, ok := months[transaction.Category][month]
+		if !ok {
+			totals = &categoryMonth{largest: transaction}
+			months[transaction.Category][month] = totals
+		}
+		totals.total += math.Abs(transaction.Amount)
+		if math.Abs(transaction.Amount) > math.Abs(totals.largest.Amount) {
+			totals.largest = transaction
+		}
+	}
+
+	var anomalies []Anomaly
+	for category, totals := range months {
+		var keys []time.Time
+		for month := range totals {
+			keys = append(keys, month)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].Before(keys[j])
+		})
+
+		// Months without spending count towards the history as zero
+		var history []float64
+		for month := keys[0]; !month.After(keys[len(keys)-1]); month = Monthly.next(month) {
+			total := 0.0
+			if totals[month] != nil {
+				total = totals[month].total
+			}
+
+			if len(history) >= options.MinHistory && totals[month] != nil {
+				mean, stdDev := meanStdDev(history)
+				deviations, severity, ok := deviationSeverity(total, mean, stdDev, options.Sensitivity)
+				if ok {
+					anomalies = append(anomalies, Anomaly{
+						Transaction: totals[month].largest,
+						Reason:      fmt.Sprintf("%s spend of $%.2f in %s is %s", category, total, Monthly.label(month), aboveNormal(total, mean, stdDev, deviations, "its usual")),
+						Severity:    severity,
+					})
+				}
+			}
+
+			history = append(history, total)
+		}
+	}
+
+	return anomalies
+}
+
+// findNewPayees flags first payments to a payee above the threshold. Payees
+// first seen in the first month of history are not new, just the start of it.
+func findNewPayees(expenses Transactions, options AnomalyOptions) []Anomaly {
+	if len(expenses) == 0 || options.NewPayeeThreshold <= 0 {
+		return nil
+	}
+
+	sorted := chronological(expenses)
+	settled := sorted[0].Date.AddDate(0, 1, 0)
+
+	seen := make(map[string]bool)
+	var anomalies []Anomaly
+	for _, transaction := range sorted {
+		payee := strings.ToLower(transaction.Description)
+		if seen[payee] {
+			continue
+		}
+		seen[payee] = true
+
+		amount := math.Abs(transaction.Amount)
+		if transaction.Date.Before(settled) || amount < options.NewPayeeThreshold {
+			continue
+		}
+
+		severity := Low
+		switch {
+		case amount >= 5*options.NewPayeeThreshold:
+			severity = High
+		case amount >= 2*options.NewPayeeThreshold:
+			severity = Medium
+		}
+
+		anomalies = append(anomalies, Anomaly{
+			Transaction: transaction,
+			Reason:      fmt.Sprintf("first payment to a new payee, above $%.2f", options.NewPayeeThreshold),
+			Severity:    severity,
+		})
+	}
+
+	return anomalies
+}
+
+// findDuplicateCharges flags expenses with the same payee, amount and account
+// as another on the same day.
+func findDuplicateCharges(expenses Transactions) []Anomaly {
+	type charge struct {
+		date        time.Time
+		description string
+		amount      float64
+		account     string
+	}
+
+	seen := make(map[charge]int)
+	var anomalies []Anomaly
+	for _, transaction := range chronological(expenses) {
+		key := charge{transaction.Date, transaction.Description, transaction.Amount, transaction.Account}
+		seen[key]++
+		if seen[key] == 1 {
+			continue
+		}
+
+		anomalies = append(anomalies, Anomaly{
+			Transaction: transaction,
+			Reason:      fmt.Sprintf("possible duplicate: charge %d of the same amount to this payee on the same day", seen[key]),
+			Severity:    Medium,
+		})
+	}
+
+	return anomalies
+}
+
+// findAnomalies runs every check over the loaded expenses, most severe first.
+func (app *application) findAnomalies(options AnomalyOptions) []Anomaly {
+	expenses := app.filterTransactionsByType(*app.transactions, Expense)
+
+	var anomalies []Anomaly
+	anomalies = append(anomalies, findPayeeOutliers(expenses, options)...)
+	anomalies = append(anomalies, findCategorySpikes(expenses, options)...)
+	anomalies = append(anomalies, findNewPayees(expenses, options)...)
+	anomalies = append(anomalies, findDuplicateCharges(expenses)...)
+
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		if anomalies[i].Severity != anomalies[j].Severity {
+			return anomalies[i].Severity > anomalies[j].Severity
+		}
+		return anomalies[i].Transaction.Date.After(anomalies[j].Transaction.Date)
+	})
+
+	return anomalies
+}
+
+func (app *application) printAnomalies(anomalies []Anomaly) {
+	if len(anomalies) == 0 {
+		fmt.Println("No anomalies found")
+		return
+	}
+
+	for _, anomaly := range anomalies {
+		transaction := anomaly.Transaction
+		fmt.Printf("%-6s  %s  %10.2f  %s\n", anomaly.Severity, transaction.Date.Format("02-01-2006"), transaction.Amount, transaction.Description)
+		fmt.Printf("        %s\n", anomaly.Reason)
+	}
+	fmt.Println()
+	fmt.Printf("Anomalies: %d\n", len(anomalies))
+}
+
+// anomaliesCommand reports unusual expenses.
+func (app *application) anomaliesCommand(args []string) error {
+	fs, src := newFlagSet("anomalies")
+	sensitivity := fs.Float64("s", 3, "standard deviations above normal to flag.\nLower values flag more")
+	newPayee := fs.Float64("new", 200, "flag first payments to a new payee above this amount.\n0 disables the check")
+	minHistory := fs.Int("min", 3, "earlier payments or months needed before comparing against them")
+	minSeverity := fs.String("severity", "low", "lowest severity to report: low, medium or high")
+	fs.Parse(args)
+
+	severity, err := parseSeverity(*minSeverity)
+	if err != nil {
+		return err
+	}
+	if *sensitivity <= 0 {
+		return fmt.Errorf("-s must be greater than 0")
+	}
+
+	if err := app.load(src); err != nil {
+		return err
+	}
+
+	anomalies := app.findAnomalies(AnomalyOptions{
+		Sensitivity:       *sensitivity,
+		NewPayeeThreshold: *newPayee,
+		MinHistory:        *minHistory,
+	})
+
+	var reported []Anomaly
+	for _, anomaly := range anomalies {
+		if anomaly.Severity >= severity {
+			reported = append(reported, anomaly)
+		}
+	}
+
+	app.printAnomalies(reported)
+	return nil
+}
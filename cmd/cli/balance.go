@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// balanceTolerance is the largest difference between two balances that is
+// still treated as equal, to absorb floating point rounding.
+const balanceTolerance = 0.005
+
+type BalanceCheck struct {
+	Transaction Transaction
+	// Computed is our running balance after this transaction.
+	Computed float64
+	// Difference is Computed minus the bank's balance. It is only meaningful
+	// when the transaction has a bank balance.
+	Difference float64
+}
+
+type Divergence struct {
+	Index  int
+	Check  BalanceCheck
+	Reason string
+}
+
+// chronological returns a copy of the transactions ordered oldest first.
+// Bank exports are usually newest first, so the rows are reversed before the
+// stable sort to keep same-day rows in the order the bank applied them.
+func chronological(transactions Transactions) Transactions {
+	sorted := make(Transactions, len(transactions))
+	copy(sorted, transactions)
+
+	if len(sorted) > 1 && sorted[0].Date.After(sorted[len(sorted)-1].Date) {
+		for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+			sorted[i], sorted[j] = sorted[j], sorted[i]
+		}
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	return sorted
+}
+
+// inferOpeningBalance works out the balance before the first transaction from
+// the bank's balance on the first row that has one.
+func inferOpeningBalance(transactions Transactions) (float64, bool) {
+	var sum float64
+	for _, transaction := range transactions {
+		sum += transaction.Amount
+		if transaction.HasBalance {
+			return transaction.Balance - sum, true
+		}
+	}
+
+	return 0, false
+}
+
+// calculateRunningBalances computes a running balance from the opening balance
+// independently of the bank's figures, and compares the two on each row.
+func calculateRunningBalances(transactions Transactions, opening float64) []BalanceCheck {
+	checks := make([]BalanceCheck, 0, len(transactions))
+	balance := opening
+	for _, transaction := range transactions {
+		balance += transaction.Amount
+		check := BalanceCheck{
+			Transaction: transaction,
+			Computed:    balance,
+		}
+		if transaction.HasBalance {
+			check.Difference = balance - transaction.Balance
+		}
+		checks = append(checks, check)
+	}
+
+	return checks
+}
+
+// findDivergence returns the first row where the computed balance disagrees
+// with the bank's, along with a best guess at the cause, or nil if they agree.
+func findDivergence(checks []BalanceCheck, opening float64) *Divergence {
+	previousBank := opening
+	for i, check := range checks {
+		transaction := check.Transaction
+		if !transaction.HasBalance {
+			previousBank += transaction.Amount
+			continue
+		}
+
+		if math.Abs(check.Difference) > balanceTolerance {
+			bankMovement := transaction.Balance - previousBank
+			var reason string
+			switch {
+			case nearlyEqual(bankMovement, -transaction.Amount):
+				reason = fmt.Sprintf("row appears mis-signed: the bank balance moved by $%.2f but the row is $%.2f", bankMovement, transaction.Amount)
+			case nearlyEqual(bankMovement, 0) && isDuplicateOf(checks[:i], transaction):
+				reason = "row appears duplicated: the bank balance did not move and an identical row comes before it"
+			default:
+				reason = fmt.Sprintf("a row of $%.2f appears to be missing before this one: the bank balance moved by $%.2f but the row is $%.2f", bankMovement-transaction.Amount, bankMovement, transaction.Amount)
+			}
+
+			return &Divergence{
+				Index:  i,
+				Check:  check,
+				Reason: reason,
+			}
+		}
+
+		previousBank = transaction.Balance
+	}
+
+	return nil
+}
+
+// isDuplicateOf reports whether an earlier row has the same date, amount and
+// description as the transaction.
+func isDuplicateOf(checks []BalanceCheck, transaction Transaction) bool {
+	for _, check := range checks {
+		earlier := check.Transaction
+		if earlier.Date.Equal(transaction.Date) && earlier.Amount == transaction.Amount && earlier.Description == transaction.Description {
+			return true
+		}
+	}
+
+	return false
+}
+
+func nearlyEqual(a, b float64) bool {
+	return math.Abs(a-b) <= balanceTolerance
+}
+
+// printRunningBalances prints each row with our balance next to the bank's.
+func (app *application) printRunningBalances(checks []BalanceCheck) {
+	fmt.Printf("%-10s  %12s  %12s  %12s  %s\n", "Date", "Amount", "Computed", "Bank", "Description")
+	for _, check := range checks {
+		bank := ""
+		if check.Transaction.HasBalance {
+			bank = fmt.Sprintf("%.2f", check.Transaction.Balance)
+		}
+		fmt.Printf("%-10s  %12.2f  %12.2f  %12s  %s\n",
+			check.Transaction.Date.Format("02-01-2006"),
+			check.Transaction.Amount,
+			check.Computed,
+			bank,
+			check.Transaction.Description)
+	}
+	fmt.Println()
+}
+
+// reconcileCommand checks the running balance of a statement against the
+// bank's figures and, when given, its opening and closing balances.
+func (app *application) reconcileCommand(args []string) error {
+	fs, src := newFlagSet("reconcile")
+	opening := fs.String("open", "", "statement opening balance.\nInferred from the first row's balance when empty")
+	closing := fs.String("close", "", "statement closing balance")
+	from := fs.String("from", "", "first date of the statement period")
+	to := fs.String("to", "", "last date of the statement period")
+	verbose := fs.Bool("v", false, "print the running balance of every row")
+	fs.Parse(args)
+
+	if err := app.load(src); err != nil {
+		return err
+	}
+
+	if len(app.accountNames()) > 1 {
+		return fmt.Errorf("reconcile one account at a time by choosing it with -a")
+	}
+
+	var fromDate, toDate time.Time
+	var err error
+	if *from != "" {
+		if fromDate, err = app.parseDate(*from); err != nil {
+			return err
+		}
+	}
+	if *to != "" {
+		if toDate, err = app.parseEndDate(*to); err != nil {
+			return err
+		}
+	}
+
+	// Restrict to the statement period, including both boundary days. Edits
+	// are ignored since the bank's balances don't know about them.
+	period := inclusiveRange(fromDate, toDate).Filter(chronological(app.imported))
+	if len(period) == 0 {
+		return fmt.Errorf("no transactions found in the statement period")
+	}
+
+	var openingBalance float64
+	if *opening != "" {
+		value, err := strconv.ParseFloat(*opening, 64)
+		if err != nil {
+			return fmt.Errorf("invalid opening balance: %w", err)
+		}
+		openingBalance = value
+	} else {
+		value, ok := inferOpeningBalance(period)
+		if !ok {
+			return fmt.Errorf("the file has no balance column; provide the statement opening balance with -open")
+		}
+		openingBalance = value
+	}
+
+	checks := calculateRunningBalances(period, openingBalance)
+	if *verbose {
+		app.printRunningBalances(checks)
+	}
+
+	first, last := period[0].Date, period[len(period)-1].Date
+	fmt.Printf("Reconciling %d transactions from %s to %s\n", len(period), first.Format("02-01-2006"), last.Format("02-01-2006"))
+	fmt.Printf("Opening Balance: $%.2f\n", openingBalance)
+	fmt.Printf("Computed Closing Balance: $%.2f\n", checks[len(checks)-1].Computed)
+
+	reconciled := true
+	if divergence := findDivergence(checks, openingBalance); divergence != nil {
+		reconciled = false
+		transaction := divergence.Check.Transaction
+		fmt.Println()
+		fmt.Printf("Balances diverge at row %d (%s, $%.2f, %s)\n", divergence.Index+1, transaction.Date.Format("02-01-2006"), transaction.Amount, transaction.Description)
+		fmt.Printf("  Computed: $%.2f  Bank: $%.2f  Difference: $%.2f\n", divergence.Check.Computed, transaction.Balance, divergence.Check.Difference)
+		fmt.Printf("  %s\n", divergence.Reason)
+	}
+
+	if *closing != "" {
+		closingBalance, err := strconv.ParseFloat(*closing, 64)
+		if err != nil {
+			return fmt.Errorf("invalid closing balance: %w", err)
+		}
+
+		var total float64
+		for _, transaction := range period {
+			total += transaction.Amount
+		}
+
+		fmt.Println()
+		fmt.Printf("Statement Closing Balance: $%.2f\n", closingBalance)
+		fmt.Printf("Period Total: $%.2f\n", total)
+		if difference := openingBalance + total - closingBalance; !nearlyEqual(difference, 0) {
+			reconciled = false
+			fmt.Printf("Opening balance plus period total is off by $%.2f\n", difference)
+		}
+	}
+
+	fmt.Println()
+	if reconciled {
+		fmt.Println("Reconciled")
+	} else {
+		fmt.Println("Not reconciled")
+	}
+
+	return nil
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bill is a payment due regularly, declared in the config.
+type Bill struct {
+	// Payee is matched against descriptions to find payments, with terms
+	// separated by "|" as with -in.
+	Payee  string  `yaml:"payee"`
+	Amount float64 `yaml:"amount"`
+	// Due is the first due date, and Every is week, fortnight, month,
+	// quarter or year.
+	Due      string `yaml:"due"`
+	Every    string `yaml:"every"`
+	Category string `yaml:"category"`
+}
+
+// ScheduledBill is a declared bill, or one detected from regular payments.
+type ScheduledBill struct {
+	Name     string
+	Payee    string
+	Amount   float64
+	Every    string
+	Category string
+	First    time.Time
+	// Detected is set for bills found in the ledger rather than declared.
+	Detected bool
+}
+
+// dueDates returns the days the bill is due within a range.
+func (b ScheduledBill) dueDates(span DateRange) []time.Time {
+	var dates []time.Time
+	for date := b.First; span.Contains(date) || calendarDay(date) < calendarDay(span.Start); {
+		if span.Contains(date) {
+			dates = append(dates, date)
+		}
+		next, err := recurrence(date, b.Every)
+		if err != nil {
+			break
+		}
+		date = next
+	}
+	return dates
+}
+
+// billIntervals are the gaps in days between payments that make a bill
+// recurring, from shortest to longest.
+var billIntervals = []struct {
+	every    string
+	min, max int
+}{
+	{"week", 6, 8},
+	{"fortnight", 13, 15},
+	{"month", 27, 33},
+	{"quarter", 85, 97},
+	{"year", 355, 375},
+}
+
+// detectBills finds payees paid at a regular interval, with each payment
+// within a quarter of the usual amount. minPayments is the number of payments
+// needed before a payee counts.
+func detectBills(expenses Transactions, minPayments int) []ScheduledBill {
+	byPayee := make(map[string]Transactions)
+	for _, transaction := range chronological(expenses) {
+		byPayee[transaction.Description] = append(byPayee[transaction.Description], transaction)
+	}
+
+	var bills []ScheduledBill
+	for payee, payments := range byPayee {
+		if len(payments) < minPayments || len(payments) < 2 {
+			continue
+		}
+
+		var gaps, amounts []float64
+		for i, payment := range payments {
+			amounts = append(amounts, -payment.Amount)
+			if i > 0 {
+				gaps = append(gaps, math.Round(payment.Date.Sub(payments[i-1].Date).Hours()/24))
+			}
+		}
+		sort.Float64s(gaps)
+		sort.Float64s(amounts)
+		usual := amounts[len(amounts)/2]
+
+		every := ""
+		for _, interval := range billIntervals {
+			if gaps[0] >= float64(interval.min) && gaps[len(gaps)-1] <= float64(interval.max) {
+				every = interval.every
+				break
+			}
+		}
+		if every == "" || usual <= 0 || amounts[0] < usual*0.75 || amounts[len(amounts)-1] > usual*1.25 {
+			continue
+		}
+
+		last := payments[len(payments)-1]
+		bills = append(bills, ScheduledBill{
+			Name:     payee,
+			Payee:    payee,
+			Amount:   -last.Amount,
+			Every:    every,
+			Category: last.Category,
+			First:    last.Date,
+			Detected: true,
+		})
+	}
+
+	return bills
+}
+
+// scheduledBills returns the bills in the config, and those detected in the
+// transactions that aren't already declared.
+func (app *application) scheduledBills(transactions Transactions, minPayments int) ([]ScheduledBill, error) {
+	var bills []ScheduledBill
+	for name, bill := range app.config.Bills {
+		if bill.Payee == "" || bill.Due == "" {
+			return nil, fmt.Errorf("bill %q: needs a payee and a due date", name)
+		}
+		first, err := app.parseDate(bill.Due)
+		if err != nil {
+			return nil, fmt.Errorf("bill %q: %w", name, err)
+		}
+		every := strings.ToLower(bill.Every)
+		if _, err := recurrence(first, every); err != nil {
+			return nil, fmt.Errorf("bill %q: %w", name, err)
+		}
+
+		bills = append(bills, ScheduledBill{
+			Name:     name,
+			Payee:    bill.Payee,
+			Amount:   math.Abs(bill.Amount),
+			Every:    every,
+			Category: bill.Category,
+			First:    first,
+		})
+	}
+
+	if minPayments > 0 {
+		expenses := app.filterTransactionsByType(transactions, Expense)
+		for _, detected := range detectBills(expenses, minPayments) {
+			declared := false
+			for _, bill := range bills {
+				if !bill.Detected && matchesAny(detected.Payee, bill.Payee) {
+					declared = true
+					break
+				}
+			}
+			if !declared {
+				bills = append(bills, detected)
+			}
+		}
+	}
+
+	return bills, nil
+}
+
+// BillDue is a day a bill falls due, and whether it was paid.
+type BillDue struct {
+	Bill ScheduledBill
+	Date time.Time
+	// Payment is the payment found for it, if any.
+	Payment *Transaction
+	// Missed is set for declared bills with no payment once the grace days
+	// after the due date have passed.
+	Missed bool
+}
+
+// billsDue lists when each bill falls due within a range, earliest first. A
+// payment counts towards a due date when it is made after the previous due
+// date and no more than grace days after this one. Each payment only counts
+// once, so a late payment doesn't also pay the next due date.
+func billsDue(bills []ScheduledBill, expenses Transactions, span DateRange, today time.Time, grace int) []BillDue {
+	expenses = chronological(expenses)
+	var due []BillDue
+	for _, bill := range bills {
+		used := make(map[int]bool)
+		// Walk every due date from the first, so each payment window starts
+		// the day after the previous due date
+		previous := time.Time{}
+		for _, date := range bill.dueDates(DateRange{Start: bill.First, End: span.Last()}) {
+			window := DateRange{Start: previous.AddDate(0, 0, 1), End: date.AddDate(0, 0, grace)}
+			if previous.IsZero() {
+				window.Start = time.Time{}
+			}
+			previous = date
+
+			entry := BillDue{Bill: bill, Date: date}
+			for i := range expenses {
+				if !used[i] && window.Contains(expenses[i].Date) && matchesAny(expenses[i].Description, bill.Payee) {
+					used[i] = true
+					entry.Payment = &expenses[i]
+					break
+				}
+			}
+			if !span.Contains(date) {
+				continue
+			}
+			entry.Missed = entry.Payment == nil && !bill.Detected && calendarDay(window.End) < calendarDay(today)
+			due = append(due, entry)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		if !due[i].Date.Equal(due[j].Date) {
+			return due[i].Date.Before(due[j].Date)
+		}
+		return due[i].Bill.Name < due[j].Bill.Name
+	})
+	return due
+}
+
+// status describes whether a bill was paid.
+func (d BillDue) status() string {
+	switch {
+	case d.Payment != nil:
+		return "paid " + d.Payment.Date.Format("02-01-2006")
+	case d.Missed:
+		return "MISSED"
+	case d.Bill.Detected:
+		return "detected"
+	}
+	return "due"
+}
+
+// printBillsDue lists due dates with their amounts and status.
+func (app *application) printBillsDue(due []BillDue) {
+	if len(due) == 0 {
+		fmt.Println("No bills due")
+		return
+	}
+
+	total := 0.0
+	fmt.Printf("%-10s  %-20s  %10s  %-9s  %s\n", "Due", "Bill", "Amount", "Every", "Status")
+	for _, d := range due {
+		fmt.Printf("%-10s  %-20s  %10.2f  %-9s  %s\n", d.Date.Format("02-01-2006"),
+			fitLabel(d.Bill.Name, 20), d.Bill.Amount, d.Bill.Every, d.status())
+		if d.Payment == nil {
+			total += d.Bill.Amount
+		}
+	}
+	fmt.Println()
+	fmt.Printf("Unpaid: %s\n", app.money(total))
+}
+
+// printMonthGrid prints a calendar of the month with the days bills are due
+// marked, weeks starting on Monday.
+func printMonthGrid(month time.Time, due []BillDue) {
+	marks := make(map[int]string)
+	for _, d := range due {
+		switch {
+		case d.Missed:
+			marks[d.Date.Day()] = "!"
+		case marks[d.Date.Day()] == "":
+			marks[d.Date.Day()] = "*"
+		}
+	}
+
+	title := month.Format("January 2006")
+	fmt.Printf("%*s\n", (35+len(title))/2, title)
+	fmt.Println("  Mon  Tue  Wed  Thu  Fri  Sat  Sun")
+	offset := (int(month.Weekday()) + 6) % 7
+	fmt.Print(strings.Repeat("     ", offset))
+	days := month.AddDate(0, 1, -1).Day()
+	for day := 1; day <= days; day++ {
+		mark := marks[day]
+		if mark == "" {
+			mark = " "
+		}
+		fmt.Printf("%4d%s", day, mark)
+		if (offset+day)%7 == 0 || day == days {
+			fmt.Println()
+		}
+	}
+	fmt.Println()
+	fmt.Println("* due  ! missed")
+}
+
+// icsText escapes text for an iCalendar property value.
+func icsText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+// writeICS writes the due dates as all-day events in an iCalendar file.
+func (app *application) writeICS(filename string, due []BillDue) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	line := func(format string, args ...any) {
+		fmt.Fprintf(w, format+"\r\n", args...)
+	}
+
+	stamp := now().UTC().Format("20060102T150405Z")
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//FineAnts//Bills//EN")
+	line("CALSCALE:GREGORIAN")
+	for _, d := range due {
+		uid := strings.ToLower(strings.Join(strings.Fields(d.Bill.Name), "-"))
+		line("BEGIN:VEVENT")
+		line("UID:%s-%s@fineants", icsText(uid), d.Date.Format("20060102"))
+		line("DTSTAMP:%s", stamp)
+		line("DTSTART;VALUE=DATE:%s", d.Date.Format("20060102"))
+		line("DTEND;VALUE=DATE:%s", d.Date.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:%s", icsText(fmt.Sprintf("%s %s", d.Bill.Name, app.money(d.Bill.Amount))))
+		line("DESCRIPTION:%s", icsText(fmt.Sprintf("%s due every %s", app.money(d.Bill.Amount), d.Bill.Every)))
+		if d.Bill.Category != "" {
+			line("CATEGORIES:%s", icsText(d.Bill.Category))
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// billFlags holds the flags shared by the bill commands.
+type billFlags struct {
+	at     *string
+	grace  *int
+	detect *int
+}
+
+func addBillFlags(fs *flag.FlagSet) billFlags {
+	return billFlags{
+		at:     fs.String("at", "today", "date to look from"),
+		grace:  fs.Int("grace", 3, "days after a due date a payment may arrive before the bill is missed"),
+		detect: fs.Int("detect", 3, "regular payments needed to detect a bill from the ledger.\n0 only uses the bills in the config"),
+	}
+}
+
+// loadBills loads the ledger and returns its expenses, the bills and the date
+// to look from.
+func (app *application) loadBills(src source, flags billFlags) (Transactions, []ScheduledBill, time.Time, error) {
+	if err := app.load(src); err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	today, err := app.parseDate(*flags.at)
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+
+	bills, err := app.scheduledBills(*app.transactions, *flags.detect)
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	if len(bills) == 0 {
+		return nil, nil, time.Time{}, errors.New("no bills found: add them under bills in the config, or lower -detect")
+	}
+
+	return app.filterTransactionsByType(*app.transactions, Expense), bills, today, nil
+}
+
+// dueCommand lists the bills due in the coming days, and declared bills
+// recently missed.
+func (app *application) dueCommand(args []string) error {
+	fs, src := newFlagSet("due")
+	flags := addBillFlags(fs)
+	days := fs.Int("days", 14, "days ahead to list bills due")
+	missed := fs.Int("missed", 30, "days back to look for missed bills")
+	ics := fs.String("ics", "", "also write the bills due to this iCalendar file")
+	fs.Parse(args)
+
+	if *days < 0 || *missed < 0 {
+		return errors.New("-days and -missed can't be negative")
+	}
+
+	expenses, bills, today, err := app.loadBills(src, flags)
+	if err != nil {
+		return err
+	}
+
+	var listed []BillDue
+	span := inclusiveRange(today.AddDate(0, 0, -*missed), today.AddDate(0, 0, *days))
+	for _, d := range billsDue(bills, expenses, span, today, *flags.grace) {
+		if calendarDay(d.Date) >= calendarDay(today) || d.Missed {
+			listed = append(listed, d)
+		}
+	}
+
+	app.printBillsDue(listed)
+	if *ics != "" {
+		if err := app.writeICS(*ics, listed); err != nil {
+			return err
+		}
+		fmt.Printf("Calendar written to %s\n", *ics)
+	}
+	return nil
+}
+
+// calendarCommand prints a month of bills as a grid, and can export the
+// upcoming due dates as an iCalendar file.
+func (app *application) calendarCommand(args []string) error {
+	fs, src := newFlagSet("calendar")
+	flags := addBillFlags(fs)
+	month := fs.String("month", "this-month", "month to show, such as 2024-03 or last-month")
+	ics := fs.String("ics", "", "write the upcoming due dates to this iCalendar file")
+	days := fs.Int("days", 365, "days ahead to export with -ics")
+	fs.Parse(args)
+
+	expenses, bills, today, err := app.loadBills(src, flags)
+	if err != nil {
+		return err
+	}
+
+	start, err := app.parseDate(*month)
+	if err != nil {
+		return err
+	}
+	start = Monthly.start(start)
+	due := billsDue(bills, expenses, DateRange{Start: start, End: Monthly.next(start), Exclusive: true}, today, *flags.grace)
+
+	printMonthGrid(start, due)
+	if len(due) > 0 {
+		fmt.Println()
+		app.printBillsDue(due)
+	}
+
+	if *ics != "" {
+		upcoming := billsDue(bills, expenses, inclusiveRange(today, today.AddDate(0, 0, *days)), today, *flags.grace)
+		if err := app.writeICS(*ics, upcoming); err != nil {
+			return err
+		}
+		fmt.Println()
+		fmt.Printf("%d due dates written to %s\n", len(upcoming), *ics)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Uncategorized is the category of transactions no rule matches.
+const Uncategorized = "Uncategorized"
+
+// CategoryRule assigns a category to transactions whose description contains
+// any of its "|" separated terms, matched the same way as -in and -ex.
+type CategoryRule struct {
+	Terms    string
+	Category string
+}
+
+// loadCategoryRules reads category rules from a CSV file of terms,category
+// rows, with an optional header. Rules are applied in file order and the
+// first match wins.
+func loadCategoryRules(filename string) ([]CategoryRule, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []CategoryRule
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "terms") {
+			continue
+		}
+		if len(record) < 2 || strings.TrimSpace(record[0]) == "" || strings.TrimSpace(record[1]) == "" {
+			return nil, fmt.Errorf("%s line %d: expected terms and category", filename, i+1)
+		}
+
+		rules = append(rules, CategoryRule{
+			Terms:    strings.TrimSpace(record[0]),
+			Category: strings.TrimSpace(record[1]),
+		})
+	}
+
+	return rules, nil
+}
+
+// categorize sets the category of every transaction from the rules.
+func categorize(transactions Transactions, rules []CategoryRule) {
+	for i := range transactions {
+		transactions[i].Category = Uncategorized
+		for _, rule := range rules {
+			if matchesAny(transactions[i].Description, rule.Terms) {
+				transactions[i].Category = rule.Category
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// chartStyle holds what the terminal can display.
+type chartStyle struct {
+	width   int
+	unicode bool
+}
+
+var (
+	unicodeBarParts = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+	unicodeSparks   = []rune("▁▂▃▄▅▆▇█")
+	asciiSparks     = []rune("_.-~=+*#")
+)
+
+// detectChartStyle works out the terminal width, falling back to $COLUMNS and
+// then 80 columns, and whether the locale can display Unicode.
+func detectChartStyle(forceASCII bool, width int) chartStyle {
+	style := chartStyle{width: width}
+
+	if style.width <= 0 {
+		if columns, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && columns > 0 {
+			style.width = columns
+		} else if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+			style.width = columns
+		} else {
+			style.width = 80
+		}
+	}
+
+	if !forceASCII {
+		for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+			if value := os.Getenv(name); value != "" {
+				value = strings.ToLower(value)
+				style.unicode = strings.Contains(value, "utf-8") || strings.Contains(value, "utf8")
+				break
+			}
+		}
+	}
+
+	return style
+}
+
+// bar returns a bar of the given length in columns, using partial blocks for
+// the fractional part when Unicode is available.
+func (s chartStyle) bar(length float64, fill string) string {
+	if length <= 0 {
+		return ""
+	}
+	if !s.unicode {
+		return strings.Repeat(fill, int(math.Round(length)))
+	}
+
+	whole := int(length)
+	part := int((length - float64(whole)) * 8)
+	return strings.Repeat("█", whole) + unicodeBarParts[part]
+}
+
+// sparkline returns one character per value scaled between the smallest and
+// largest value.
+func (s chartStyle) sparkline(values []float64) string {
+	sparks := asciiSparks
+	if s.unicode {
+		sparks = unicodeSparks
+	}
+
+	minimum, maximum := math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		minimum = math.Min(minimum, value)
+		maximum = math.Max(maximum, value)
+	}
+
+	var line strings.Builder
+	for _, value := range values {
+		index := 0
+		if maximum > minimum {
+			index = int((value - minimum) / (maximum - minimum) * float64(len(sparks)-1))
+		}
+		line.WriteRune(sparks[index])
+	}
+
+	return line.String()
+}
+
+// fitLabel pads or truncates a label to exactly width columns.
+func fitLabel(label string, width int) string {
+	if utf8.RuneCountInString(label) > width {
+		runes := []rune(label)
+		return string(runes[:width-1]) + "~"
+	}
+	return label + strings.Repeat(" ", width-utf8.RuneCountInString(label))
+}
+
+// labelWidth returns the width of the widest label, capped at a third of the
+// terminal.
+func (s chartStyle) labelWidth(labels []string) int {
+	width := 1
+	for _, label := range labels {
+		if length := utf8.RuneCountInString(label); length > width {
+			width = length
+		}
+	}
+
+	if limit := s.width / 3; width > limit && limit >= 8 {
+		width = limit
+	}
+	return width
+}
+
+// barChart returns a horizontal bar chart of trends, scaled so the largest
+// amount fills the terminal.
+func (s chartStyle) barChart(trends []Trend) []string {
+	var labels []string
+	largest := 0.0
+	for _, trend := range trends {
+		labels = append(labels, trend.Description)
+		largest = math.Max(largest, math.Abs(trend.TotalAmount))
+	}
+
+	labelWidth := s.labelWidth(labels)
+	barWidth := s.width - labelWidth - 16
+	if barWidth < 10 || largest == 0 {
+		barWidth = 10
+	}
+
+	var lines []string
+	for _, trend := range trends {
+		amount := math.Abs(trend.TotalAmount)
+		length := 0.0
+		if largest > 0 {
+			length = amount / largest * float64(barWidth)
+		}
+		lines = append(lines, fmt.Sprintf("%s │%s %.2f", fitLabel(trend.Description, labelWidth), s.bar(length, "#"), amount))
+	}
+
+	if !s.unicode {
+		for i := range lines {
+			lines[i] = strings.Replace(lines[i], "│", "|", 1)
+		}
+	}
+
+	return lines
+}
+
+// cashFlowChart returns one stacked bar per period showing income split into
+// expenses and savings, with overspending past the end of income, followed by
+// a line of the savings rate over the periods.
+func (s chartStyle) cashFlowChart(labels []string, incomes, expenses []float64) []string {
+	largest := 0.0
+	for i := range labels {
+		largest = math.Max(largest, math.Max(incomes[i], expenses[i]))
+	}
+
+	expenseFill, savingFill, overFill := "█", "░", "▒"
+	if !s.unicode {
+		expenseFill, savingFill, overFill = "#", ".", "!"
+	}
+
+	labelWidth := s.labelWidth(append([]string{"Savings rate"}, labels...))
+	barWidth := s.width - labelWidth - 12
+	if barWidth < 10 || largest == 0 {
+		barWidth = 10
+	}
+	scale := func(amount float64) int {
+		if largest <= 0 {
+			return 0
+		}
+		return int(math.Round(amount / largest * float64(barWidth)))
+	}
+
+	var lines []string
+	var rates []float64
+	for i, label := range labels {
+		spent := math.Min(expenses[i], incomes[i])
+		bar := strings.Repeat(expenseFill, scale(spent)) +
+			strings.Repeat(savingFill, scale(incomes[i])-scale(spent)) +
+			strings.Repeat(overFill, scale(expenses[i])-scale(spent))
+
+		rate := 0.0
+		if incomes[i] > 0 {
+			rate = (incomes[i] - expenses[i]) / incomes[i] * 100
+		}
+		rates = append(rates, rate)
+
+		lines = append(lines, fmt.Sprintf("%s %s %5.0f%%", fitLabel(label, labelWidth), fitLabel(bar, barWidth+1), rate))
+	}
+
+	lines = append(lines,
+		"",
+		fmt.Sprintf("%s %s", fitLabel("Savings rate", labelWidth), s.sparkline(rates)),
+		fmt.Sprintf("%s %s expenses  %s savings  %s overspent", strings.Repeat(" ", labelWidth), expenseFill, savingFill, overFill),
+	)
+
+	return lines
+}
+
+// monthlySpend returns each category's spend per month across the loaded
+// transactions, along with the months in order.
+func (app *application) monthlySpend() (map[string][]float64, []string) {
+	expenses := chronological(app.filterTransactionsByType(*app.transactions, Expense))
+	if len(expenses) == 0 {
+		return nil, nil
+	}
+
+	dateRanges := periodRanges(expenses[0].Date, expenses[len(expenses)-1].Date, Monthly)
+	spend := make(map[string][]float64)
+	var labels []string
+	for i, dateRange := range dateRanges {
+		labels = append(labels, Monthly.label(dateRange.Start))
+		for _, transaction := range expenses {
+			if !dateRange.Contains(transaction.Date) {
+				continue
+			}
+			if spend[transaction.Category] == nil {
+				spend[transaction.Category] = make([]float64, len(dateRanges))
+			}
+			spend[transaction.Category][i] += math.Abs(transaction.Amount)
+		}
+	}
+
+	return spend, labels
+}
+
+// sparklineChart returns a sparkline of monthly spend for each category,
+// biggest total spend first.
+func (s chartStyle) sparklineChart(spend map[string][]float64, months []string) []string {
+	type categorySpend struct {
+		name   string
+		values []float64
+		total  float64
+	}
+
+	var categories []categorySpend
+	var labels []string
+	for name, values := range spend {
+		category := categorySpend{name: name, values: values}
+		for _, value := range values {
+			category.total += value
+		}
+		categories = append(categories, category)
+		labels = append(labels, name)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].total > categories[j].total
+	})
+
+	labelWidth := s.labelWidth(labels)
+	lines := []string{fmt.Sprintf("%s %s to %s", strings.Repeat(" ", labelWidth), months[0], months[len(months)-1])}
+	for _, category := range categories {
+		values := category.values
+		// Keep the most recent months that fit on the line
+		if room := s.width - labelWidth - 30; room > 0 && len(values) > room {
+			values = values[len(values)-room:]
+		}
+		lines = append(lines, fmt.Sprintf("%s %s  latest %.2f  max %.2f",
+			fitLabel(category.name, labelWidth), s.sparkline(values), values[len(values)-1], maxOf(values)))
+	}
+
+	return lines
+}
+
+func maxOf(values []float64) float64 {
+	largest := math.Inf(-1)
+	for _, value := range values {
+		largest = math.Max(largest, value)
+	}
+	return largest
+}
+
+// categoryTrends totals expenses by category, biggest spend first.
+func (app *application) categoryTrends(topX int) []Trend {
+	totals := make(map[string]float64)
+	for _, transaction := range app.filterTransactionsByType(*app.transactions, Expense) {
+		totals[transaction.Category] += transaction.Amount
+	}
+
+	var trends Trends
+	for category, total := range totals {
+		trends = append(trends, Trend{Description: category, TotalAmount: total})
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		return trends[i].TotalAmount < trends[j].TotalAmount
+	})
+
+	if topX > 0 && len(trends) > topX {
+		trends = trends[:topX]
+	}
+
+	return trends
+}
+
+// cashFlow returns income and expenses for each period covered by the loaded
+// transactions.
+func (app *application) cashFlow(period Period) ([]string, []float64, []float64) {
+	sorted := chronological(*app.transactions)
+	var labels []string
+	var incomes, expenses []float64
+	for _, dateRange := range periodRanges(sorted[0].Date, sorted[len(sorted)-1].Date, period) {
+		var income, expense float64
+		for _, transaction := range sorted {
+			if !dateRange.Contains(transaction.Date) {
+				continue
+			}
+			if transaction.Type == Income {
+				income += transaction.Amount
+			} else {
+				expense -= transaction.Amount
+			}
+		}
+
+		labels = append(labels, period.label(dateRange.Start))
+		incomes = append(incomes, income)
+		expenses = append(expenses, expense)
+	}
+
+	return labels, incomes, expenses
+}
+
+// chartCommand draws charts of the loaded transactions in the terminal.
+func (app *application) chartCommand(args []string) error {
+	fs, src := newFlagSet("chart")
+	kind := fs.String("k", "payees", "chart to draw: payees, categories, spend or cashflow")
+	top := fs.Int("t", 10, "number of payees or categories to chart")
+	periodName := fs.String("p", string(Monthly), "period for the cashflow chart: month, quarter or year")
+	ascii := fs.Bool("ascii", false, "draw with ASCII characters only")
+	width := fs.Int("w", 0, "chart width in columns.\nDefaults to the terminal width")
+	cpiFlags := addRealFlags(fs)
+	fs.Parse(args)
+
+	period, err := parsePeriod(*periodName)
+	if err != nil {
+		return err
+	}
+
+	if err := app.load(src); err != nil {
+		return err
+	}
+	if err := app.restate(*cpiFlags.cpi, *cpiFlags.base); err != nil {
+		return err
+	}
+
+	style := detectChartStyle(*ascii, *width)
+
+	var lines []string
+	switch *kind {
+	case "payees":
+		lines = style.barChart(app.calculateTopTrends(*app.transactions, *top, Expense))
+	case "categories":
+		lines = style.barChart(app.categoryTrends(*top))
+	case "spend":
+		spend, months := app.monthlySpend()
+		if len(spend) == 0 {
+			return fmt.Errorf("no expenses to chart")
+		}
+		lines = style.sparklineChart(spend, months)
+	case "cashflow":
+		labels, incomes, expenses := app.cashFlow(period)
+		lines = style.cashFlowChart(labels, incomes, expenses)
+	default:
+		return fmt.Errorf("unknown chart %q: use payees, categories, spend or cashflow", *kind)
+	}
+
+	app.printRestatement()
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+
+	return nil
+}
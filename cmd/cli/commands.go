@@ -0,0 +1,274 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// commands maps a subcommand name to its handler. Each handler parses its own
+// flags from the arguments that follow the subcommand name.
+var commands = map[string]func(app *application, args []string) error{
+	"anomalies": (*application).anomaliesCommand,
+	"calendar":  (*application).calendarCommand,
+	"chart":     (*application).chartCommand,
+	"compare":   (*application).compareCommand,
+	"debts":     (*application).debtsCommand,
+	"due":       (*application).dueCommand,
+	"fire":      (*application).fireCommand,
+	"goals":     (*application).goalsCommand,
+	"invest":    (*application).investCommand,
+	"loan":      (*application).loanCommand,
+	"networth":  (*application).netWorthCommand,
+	"reconcile": (*application).reconcileCommand,
+	"reimburse": (*application).reimburseCommand,
+	"report":    (*application).reportCommand,
+	"share":     (*application).shareCommand,
+	"shell":     (*application).shellCommand,
+	"tag":       (*application).tagCommand,
+	"tax":       (*application).taxCommand,
+	"tui":       (*application).tuiCommand,
+	"whatif":    (*application).whatifCommand,
+}
+
+// source holds the flags that say where transactions are imported from.
+type source struct {
+	filename   *string
+	accounts   *string
+	account    *string
+	categories *string
+	tags       *string
+	edits      *string
+	timezone   *string
+	// tag is a tag predicate transactions must satisfy, like -tag.
+	tag *string
+	// keepExcluded keeps excluded transactions so they can be included again.
+	keepExcluded bool
+}
+
+// setTimezone sets the timezone transaction dates are in to the named one,
+// or the config's when name is empty.
+func (app *application) setTimezone(name string) error {
+	if name == "" {
+		name = app.config.Timezone
+	}
+	if name == "" {
+		return nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("unknown timezone %q: %w", name, err)
+	}
+	app.timezone = location
+	return nil
+}
+
+// addSourceFlags defines the -f, -accounts, -a, -c, -tags, -edits, -tz and
+// -tag flags on a flag set.
+func addSourceFlags(fs *flag.FlagSet) source {
+	return source{
+		filename:   fs.String("f", "", "filename to process"),
+		accounts:   fs.String("accounts", "", "accounts file listing each account and its CSV export"),
+		account:    fs.String("a", "", "name of the account the -f file belongs to"),
+		categories: fs.String("c", "", "category rules file of search terms and categories"),
+		tags:       fs.String("tags", "", "tag rules file of search terms and tags"),
+		edits:      fs.String("edits", "", "file of categories, tags, splits and exclusions made to transactions.\nDefaults to the -f or -accounts file with .edits.json appended"),
+		timezone:   fs.String("tz", "", "timezone transaction dates are in, such as Australia/Sydney.\nDefaults to the system timezone"),
+		tag:        fs.String("tag", "", "include transactions whose tags match, e.g. holiday-*.\nSeparate alternatives by |, combine by & and negate with !"),
+	}
+}
+
+// editsFile returns the edits file to use for a source.
+func (src source) editsFile() string {
+	switch {
+	case *src.edits != "":
+		return *src.edits
+	case *src.filename != "":
+		return *src.filename + ".edits.json"
+	}
+	return *src.accounts + ".edits.json"
+}
+
+// load imports transactions from a single file, or from every account in the
+// accounts file when no file is given.
+func (app *application) load(src source) error {
+	// Fall back to the config file for anything not given as a flag
+	if *src.accounts == "" {
+		*src.accounts = expandPath(app.config.Accounts)
+	}
+	if *src.filename == "" && *src.accounts == "" {
+		*src.filename = expandPath(app.config.File)
+	}
+	if *src.categories == "" {
+		*src.categories = expandPath(app.config.Categories)
+	}
+	if *src.tags == "" {
+		*src.tags = expandPath(app.config.Tags)
+	}
+	if err := app.setTimezone(*src.timezone); err != nil {
+		return err
+	}
+
+	profile := app.config.importProfile()
+	profile.location = app.location()
+
+	if *src.accounts != "" {
+		accounts, err := loadAccounts(*src.accounts)
+		if err != nil {
+			return fmt.Errorf("unable to load accounts: %w", err)
+		}
+		app.accounts = accounts
+	}
+
+	var transactions Transactions
+	switch {
+	case *src.filename != "" && *src.account != "":
+		account, ok := app.account(*src.account)
+		if !ok {
+			if *src.accounts != "" {
+				return fmt.Errorf("account %q is not in %s", *src.account, *src.accounts)
+			}
+			account = Account{Name: *src.account, Type: Checking, Signs: BankSigns}
+		}
+
+		imported, err := importAccount(account, *src.filename, profile)
+		if err != nil {
+			return err
+		}
+		transactions = imported
+	case *src.filename != "":
+		imported, err := importCSV(*src.filename, profile)
+		if err != nil {
+			return err
+		}
+		transactions = imported
+	case len(app.accounts) > 0:
+		for _, account := range app.accounts {
+			if account.File == "" {
+				continue
+			}
+
+			imported, err := importAccount(account, account.File, profile)
+			if err != nil {
+				return fmt.Errorf("unable to import %s: %w", account.Name, err)
+			}
+			transactions = append(transactions, imported...)
+		}
+
+		// Keep the newest first order of a single bank export
+		sort.SliceStable(transactions, func(i, j int) bool {
+			return transactions[i].Date.After(transactions[j].Date)
+		})
+	default:
+		return errors.New("please provide a filename using the -f flag or an accounts file using the -accounts flag")
+	}
+
+	if len(transactions) == 0 {
+		return errors.New("no transactions found")
+	}
+
+	var rules []CategoryRule
+	if *src.categories != "" {
+		loaded, err := loadCategoryRules(*src.categories)
+		if err != nil {
+			return fmt.Errorf("unable to load category rules: %w", err)
+		}
+		rules = loaded
+	}
+	categorize(transactions, rules)
+
+	if *src.tags != "" {
+		tagRules, err := loadTagRules(*src.tags)
+		if err != nil {
+			return fmt.Errorf("unable to load tag rules: %w", err)
+		}
+		tagTransactions(transactions, tagRules)
+	}
+	assignIDs(transactions)
+
+	edits, err := loadEdits(src.editsFile())
+	if err != nil {
+		return fmt.Errorf("unable to load edits: %w", err)
+	}
+
+	app.imported = transactions
+	app.edits = edits
+	app.editsFile = src.editsFile()
+
+	transactions = applyEdits(transactions, edits, src.keepExcluded)
+	app.transactions = &transactions
+
+	// Payees the config excludes from every report
+	if app.config.Exclude != "" {
+		transactions = app.filterTransactions(app.config.Exclude, false)
+		app.transactions = &transactions
+	}
+
+	return app.handleTagFlag(*src.tag)
+}
+
+// filters holds the flags that narrow down the transactions a subcommand
+// works on, named after the top-level flags they behave like.
+type filters struct {
+	include       *string
+	exclude       *string
+	accounts      *string
+	categories    *string
+	greaterAmount *float64
+	lesserAmount  *float64
+	greaterDate   *string
+	lesserDate    *string
+	middleDate    *string
+}
+
+// addFilterFlags defines the -in, -ex, -acct, -cat, -ga, -la, -gd, -ld and -md
+// flags on a flag set.
+func addFilterFlags(fs *flag.FlagSet) filters {
+	return filters{
+		include:       fs.String("in", "", "include transactions with this description"),
+		exclude:       fs.String("ex", "", "exclude transactions with this description"),
+		accounts:      fs.String("acct", "", "include transactions from these accounts.\nSeparate names by |"),
+		categories:    fs.String("cat", "", "include transactions in these categories.\nSeparate names by |"),
+		greaterAmount: fs.Float64("ga", 0, "include transactions greater or equal than this amount"),
+		lesserAmount:  fs.Float64("la", 0, "include transactions less or equal than this amount"),
+		greaterDate:   fs.String("gd", "", "include transactions greater or equal than this date"),
+		lesserDate:    fs.String("ld", "", "include transactions less or equal than this date"),
+		middleDate:    fs.String("md", "", "include transactions between this date.\nSeparate dates by comma, or give one expression such as last-month"),
+	}
+}
+
+// applyFilters narrows the loaded transactions down with the filter flags.
+func (app *application) applyFilters(f filters) error {
+	app.handleAccountFlag(*f.accounts)
+	app.handleExcludeTransactionsFlag(*f.exclude)
+	app.handleIncludeTransactionsFlag(*f.include)
+	app.handleGreaterAmountFlag(*f.greaterAmount)
+	app.handleLesserAmountFlag(*f.lesserAmount)
+	if *f.categories != "" {
+		transactions := filterCategories(*app.transactions, *f.categories)
+		app.transactions = &transactions
+	}
+	if err := app.handleGreaterDateFlag(*f.greaterDate); err != nil {
+		return err
+	}
+	if err := app.handleLesserDateFlag(*f.lesserDate); err != nil {
+		return err
+	}
+
+	var dates []string
+	if *f.middleDate != "" {
+		dates = strings.Split(*f.middleDate, ",")
+	}
+	return app.handleMiddleDateFlag(dates)
+}
+
+// newFlagSet returns a flag set for a subcommand, with the flags saying where
+// to import transactions from already defined.
+func newFlagSet(name string) (*flag.FlagSet, source) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	return fs, addSourceFlags(fs)
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Comparison lines up one category or payee across several periods.
+type Comparison struct {
+	Name    string
+	Amounts []float64
+}
+
+// Delta returns the change from the first period to the last.
+func (c Comparison) Delta() float64 {
+	return c.Amounts[len(c.Amounts)-1] - c.Amounts[0]
+}
+
+// Percent returns the change from the first period to the last as a
+// percentage of the first. ok is false when the first period has nothing to
+// compare against.
+func (c Comparison) Percent() (percent float64, ok bool) {
+	if c.Amounts[0] == 0 {
+		return 0, false
+	}
+	return c.Delta() / math.Abs(c.Amounts[0]) * 100, true
+}
+
+// dateRangesFlag collects repeated "start,end" date range flags.
+type dateRangesFlag []string
+
+func (d *dateRangesFlag) String() string {
+	return strings.Join(*d, " ")
+}
+
+func (d *dateRangesFlag) Set(value string) error {
+	if len(strings.Split(value, ",")) > 2 {
+		return fmt.Errorf("please provide two dates separated by comma")
+	}
+	*d = append(*d, value)
+	return nil
+}
+
+// comparisonRanges returns the periods to compare, oldest first, ending with
+// the period containing the anchor date. Each step goes back one period, or
+// one year when sameLastYear is set.
+func comparisonRanges(anchor time.Time, period Period, count int, sameLastYear bool) []DateRange {
+	dateRanges := make([]DateRange, count)
+	start := period.start(anchor)
+	for i := count - 1; i >= 0; i-- {
+		dateRanges[i] = period.periodRange(start)
+		if sameLastYear {
+			start = start.AddDate(-1, 0, 0)
+		} else {
+			start = period.start(start.AddDate(0, 0, -1))
+		}
+	}
+
+	return dateRanges
+}
+
+// calculateComparisons totals transactions of a type by category or payee in
+// each date range. Expenses are totalled as positive spend.
+func (app *application) calculateComparisons(dateRanges []DateRange, byPayee bool, txType TransactionType) []Comparison {
+	totals := make(map[string][]float64)
+	transactions := app.filterTransactionsByType(*app.transactions, txType)
+	for i, dateRange := range dateRanges {
+		for _, transaction := range transactions {
+			if !dateRange.Contains(transaction.Date) {
+				continue
+			}
+
+			name := transaction.Category
+			if byPayee {
+				name = transaction.Description
+			}
+			if _, ok := totals[name]; !ok {
+				totals[name] = make([]float64, len(dateRanges))
+			}
+			totals[name][i] += math.Abs(transaction.Amount)
+		}
+	}
+
+	var comparisons []Comparison
+	for name, amounts := range totals {
+		comparisons = append(comparisons, Comparison{
+			Name:    name,
+			Amounts: amounts,
+		})
+	}
+
+	return comparisons
+}
+
+// sortComparisons orders comparisons by the size of their absolute or
+// percentage change, biggest movers first, or by name.
+func sortComparisons(comparisons []Comparison, by string) {
+	sort.Slice(comparisons, func(i, j int) bool {
+		switch by {
+		case "pct":
+			pi, iok := comparisons[i].Percent()
+			pj, jok := comparisons[j].Percent()
+			// New spending has no percentage and sorts first
+			if iok != jok {
+				return !iok
+			}
+			return math.Abs(pi) > math.Abs(pj)
+		case "name":
+			return comparisons[i].Name < comparisons[j].Name
+		}
+		return math.Abs(comparisons[i].Delta()) > math.Abs(comparisons[j].Delta())
+	})
+}
+
+// printComparisons prints one row per category or payee with its amount in
+// each period and the change from the first period to the last.
+func (app *application) printComparisons(comparisons []Comparison, labels []string) {
+	nameWidth := len("Name")
+	for _, comparison := range comparisons {
+		if len(comparison.Name) > nameWidth {
+			nameWidth = len(comparison.Name)
+		}
+	}
+
+	width := 12
+	for _, label := range labels {
+		if len(label) > width {
+			width = len(label)
+		}
+	}
+
+	fmt.Printf("%-*s", nameWidth, "Name")
+	for _, label := range labels {
+		fmt.Printf("  %*s", width, label)
+	}
+	fmt.Printf("  %12s  %8s\n", "Change", "Change %")
+
+	totals := Comparison{Name: "Total", Amounts: make([]float64, len(labels))}
+	for _, comparison := range comparisons {
+		fmt.Printf("%-*s", nameWidth, comparison.Name)
+		for i, amount := range comparison.Amounts {
+			fmt.Printf("  %*.2f", width, amount)
+			totals.Amounts[i] += amount
+		}
+		fmt.Printf("  %+12.2f  %8s\n", comparison.Delta(), formatPercent(comparison))
+	}
+
+	fmt.Printf("%-*s", nameWidth, totals.Name)
+	for _, amount := range totals.Amounts {
+		fmt.Printf("  %*.2f", width, amount)
+	}
+	fmt.Printf("  %+12.2f  %8s\n", totals.Delta(), formatPercent(totals))
+}
+
+func formatPercent(comparison Comparison) string {
+	percent, ok := comparison.Percent()
+	if !ok {
+		if comparison.Delta() == 0 {
+			return "-"
+		}
+		return "new"
+	}
+	return fmt.Sprintf("%+.1f%%", percent)
+}
+
+// compareCommand compares spending or income by category or payee across
+// consecutive periods, the same period in earlier years, or given date ranges.
+func (app *application) compareCommand(args []string) error {
+	fs, src := newFlagSet("compare")
+	periodName := fs.String("p", string(Monthly), "period to compare: month, quarter or year")
+	versus := fs.String("vs", "last", "compare against the last period or the same period last year: last or year")
+	count := fs.Int("n", 2, "number of periods to compare")
+	at := fs.String("at", "", "date in the latest period to compare.\nDefaults to the latest transaction")
+	var dateRanges dateRangesFlag
+	fs.Var(&dateRanges, "r", "date range to compare, separated by comma, or an expression such as last-month.\nRepeat for each range")
+	byPayee := fs.Bool("payee", false, "compare payees instead of categories")
+	income := fs.Bool("income", false, "compare income instead of expenses")
+	sortBy := fs.String("sort", "delta", "sort by the biggest change: delta or pct, or by name")
+	top := fs.Int("t", 0, "number of rows to show")
+	cpiFlags := addRealFlags(fs)
+	fs.Parse(args)
+
+	period, err := parsePeriod(*periodName)
+	if err != nil {
+		return err
+	}
+	if *versus != "last" && *versus != "year" {
+		return fmt.Errorf("-vs must be last or year")
+	}
+	if *sortBy != "delta" && *sortBy != "pct" && *sortBy != "name" {
+		return fmt.Errorf("-sort must be delta, pct or name")
+	}
+
+	if err := app.load(src); err != nil {
+		return err
+	}
+	if err := app.restate(*cpiFlags.cpi, *cpiFlags.base); err != nil {
+		return err
+	}
+
+	var ranges []DateRange
+	var labels []string
+	if len(dateRanges) > 0 {
+		if len(dateRanges) < 2 {
+			return fmt.Errorf("please provide at least two date ranges to compare")
+		}
+		for _, dates := range dateRanges {
+			parts := strings.Split(dates, ",")
+			startDate, err := app.parseDate(parts[0])
+			if err != nil {
+				return err
+			}
+			endDate, err := app.parseEndDate(parts[len(parts)-1])
+			if err != nil {
+				return err
+			}
+			ranges = append(ranges, inclusiveRange(startDate, endDate))
+			labels = append(labels, fmt.Sprintf("%s..%s", startDate.Format("02/01/06"), endDate.Format("02/01/06")))
+		}
+	} else {
+		if *count < 2 {
+			return fmt.Errorf("please compare at least two periods")
+		}
+
+		anchor := (*app.transactions)[0].Date
+		for _, transaction := range *app.transactions {
+			if transaction.Date.After(anchor) {
+				anchor = transaction.Date
+			}
+		}
+		if *at != "" {
+			date, err := app.parseDate(*at)
+			if err != nil {
+				return err
+			}
+			anchor = date
+		}
+
+		ranges = comparisonRanges(anchor, period, *count, *versus == "year")
+		for _, dateRange := range ranges {
+			labels = append(labels, period.label(dateRange.Start))
+		}
+	}
+
+	txType := Expense
+	if *income {
+		txType = Income
+	}
+
+	comparisons := app.calculateComparisons(ranges, *byPayee, txType)
+	sortComparisons(comparisons, *sortBy)
+	if *top > 0 && len(comparisons) > *top {
+		comparisons = comparisons[:*top]
+	}
+
+	app.printRestatement()
+	app.printComparisons(comparisons, labels)
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds defaults read from fineants.yaml in the current directory or
+// ~/.config/fineants/config.yaml, so long command lines don't need repeating.
+type Config struct {
+	// File, Accounts, Categories and Tags are used when -f, -accounts, -c and
+	// -tags are not given.
+	File       string `yaml:"file"`
+	Accounts   string `yaml:"accounts"`
+	Categories string `yaml:"categories"`
+	Tags       string `yaml:"tags"`
+	// Profile names the import profile CSV files are read with.
+	Profile  string `yaml:"profile"`
+	Currency string `yaml:"currency"`
+	// DateFormat is the Go layout of dates given to flags such as -gd. Other
+	// common formats are understood without it.
+	DateFormat string `yaml:"date_format"`
+	// MonthFirst reads dates such as 05/03/2024 as May 3rd.
+	MonthFirst bool `yaml:"month_first"`
+	// FiscalYearStart is the month, 1 to 12, fiscal years start in.
+	FiscalYearStart int `yaml:"fiscal_year_start"`
+	// Timezone is the IANA name of the timezone transaction dates are in, used
+	// when -tz is not given.
+	Timezone string `yaml:"timezone"`
+	// Me is your name in shared expenses, Me when empty.
+	Me string `yaml:"me"`
+	// Exclude removes payees matching these terms from every report, like -ex.
+	Exclude  string                   `yaml:"exclude"`
+	Profiles map[string]ImportProfile `yaml:"profiles"`
+	Reports  map[string]SavedReport   `yaml:"reports"`
+	Goals    map[string]Goal          `yaml:"goals"`
+	// Scenarios are what-if changes to spending and income.
+	Scenarios map[string]Scenario `yaml:"scenarios"`
+	// Bills are payments due regularly, shown by due and calendar.
+	Bills map[string]Bill     `yaml:"bills"`
+	Debts map[string]Debt     `yaml:"debts"`
+	Loans map[string]LoanPlan `yaml:"loans"`
+	// Investments says where brokerage trades and prices are read from.
+	Investments InvestmentConfig `yaml:"investments"`
+	Tax         TaxConfig        `yaml:"tax"`
+	FIRE        FIREConfig       `yaml:"fire"`
+	// CPI is the consumer price index file -base restates amounts with.
+	CPI string `yaml:"cpi"`
+	// DebtBudget is what goes towards debts each month when -budget is not
+	// given.
+	DebtBudget float64 `yaml:"debt_budget"`
+
+	// path is where the config was read from.
+	path string
+}
+
+// ImportProfile describes the columns of a bank's CSV export. Columns are
+// numbered from 0. Balance is nil when the export has no balance column, and
+// DateFormat is empty to detect the format from the file's dates.
+type ImportProfile struct {
+	Date        int    `yaml:"date"`
+	Amount      int    `yaml:"amount"`
+	Description int    `yaml:"description"`
+	Balance     *int   `yaml:"balance"`
+	DateFormat  string `yaml:"date_format"`
+	MonthFirst  bool   `yaml:"month_first"`
+	Delimiter   string `yaml:"delimiter"`
+	SkipHeader  bool   `yaml:"skip_header"`
+
+	// location is the timezone dates are read in.
+	location *time.Location
+}
+
+var defaultBalanceColumn = 3
+
+// defaultProfile reads exports of date, amount, description and an optional
+// balance.
+var defaultProfile = ImportProfile{
+	Date:        0,
+	Amount:      1,
+	Description: 2,
+	Balance:     &defaultBalanceColumn,
+	Delimiter:   ",",
+}
+
+// configPaths returns the places a config file is looked for, most specific
+// first.
+func configPaths() []string {
+	if path := os.Getenv("FINEANTS_CONFIG"); path != "" {
+		return []string{path}
+	}
+
+	paths := []string{"fineants.yaml", ".fineants.yaml"}
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "fineants", "config.yaml"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".config", "fineants", "config.yaml"))
+	}
+
+	return paths
+}
+
+// loadConfig reads the first config file found. Having none is not an error.
+func loadConfig() (*Config, error) {
+	for _, path := range configPaths() {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		config := &Config{path: path}
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		if config.Profile != "" {
+			if _, ok := config.Profiles[config.Profile]; !ok {
+				return nil, fmt.Errorf("%s: import profile %q is not defined", path, config.Profile)
+			}
+		}
+
+		return config, nil
+	}
+
+	return &Config{}, nil
+}
+
+// importProfile returns the profile CSV files are read with, filling in
+// anything the config leaves out from the default profile.
+func (c *Config) importProfile() ImportProfile {
+	profile, ok := c.Profiles[c.Profile]
+	if !ok {
+		profile = defaultProfile
+	}
+
+	if c.MonthFirst {
+		profile.MonthFirst = true
+	}
+	if profile.Delimiter == "" {
+		profile.Delimiter = defaultProfile.Delimiter
+	}
+
+	return profile
+}
+
+// expandPath replaces a leading ~ with the home directory.
+func expandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// currencySymbols are printed in place of the currency code for currencies
+// that have a well-known symbol.
+var currencySymbols = map[string]string{
+	"":    "$",
+	"AUD": "$",
+	"CAD": "$",
+	"NZD": "$",
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"INR": "₹",
+}
+
+// money formats an amount in the configured currency.
+func (app *application) money(amount float64) string {
+	symbol, ok := currencySymbols[strings.ToUpper(app.config.Currency)]
+	if !ok {
+		symbol = strings.ToUpper(app.config.Currency) + " "
+	}
+
+	return fmt.Sprintf("%s%.2f", symbol, amount)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cpiPoint is the consumer price index for the period starting on a date.
+type cpiPoint struct {
+	date  time.Time
+	index float64
+}
+
+// loadCPI reads a consumer price index from a CSV file with the header
+// date,index, one row for each month or quarter.
+func loadCPI(filename string, location *time.Location, monthFirst bool) ([]cpiPoint, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var points []cpiPoint
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "date") {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("%s line %d: expected date and index", filename, i+1)
+		}
+
+		date, ok := parseAnyDate(strings.TrimSpace(record[0]), dateLayouts(monthFirst), location)
+		if !ok {
+			return nil, fmt.Errorf("%s line %d: unable to parse date %q", filename, i+1, record[0])
+		}
+		index, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil || index <= 0 {
+			return nil, fmt.Errorf("%s line %d: invalid index %q", filename, i+1, record[1])
+		}
+
+		points = append(points, cpiPoint{date: date, index: index})
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("%s: no index values found", filename)
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].date.Before(points[j].date)
+	})
+	return points, nil
+}
+
+// Restatement converts amounts into constant dollars of a base period.
+type Restatement struct {
+	File      string
+	Base      time.Time
+	BaseIndex float64
+	points    []cpiPoint
+}
+
+// indexAt returns the index of the period a date falls in. Dates before the
+// index starts use its first value.
+func (r Restatement) indexAt(date time.Time) float64 {
+	index := r.points[0].index
+	for _, point := range r.points {
+		if calendarDay(point.date) > calendarDay(date) {
+			break
+		}
+		index = point.index
+	}
+	return index
+}
+
+// apply restates the amounts of every transaction in base period dollars.
+func (r Restatement) apply(transactions Transactions) {
+	for i := range transactions {
+		factor := r.BaseIndex / r.indexAt(transactions[i].Date)
+		transactions[i].Amount *= factor
+		transactions[i].Reimbursed *= factor
+		transactions[i].Reimbursement *= factor
+	}
+}
+
+func (r Restatement) String() string {
+	return fmt.Sprintf("Amounts in %s dollars, restated with the CPI in %s (index %g)",
+		r.Base.Format("01-2006"), filepath.Base(r.File), r.BaseIndex)
+}
+
+// realFlags holds the -cpi and -base flags, which restate amounts in the
+// dollars of a base period.
+type realFlags struct {
+	cpi  *string
+	base *string
+}
+
+func addRealFlags(fs *flag.FlagSet) realFlags {
+	return realFlags{
+		cpi:  fs.String("cpi", "", "CSV of date,index to restate amounts in constant dollars.\nDefaults to cpi from the config when -base is given"),
+		base: fs.String("base", "", "date in the period whose dollars amounts are restated in.\nDefaults to the latest index"),
+	}
+}
+
+// restate converts the transactions' amounts into constant dollars when a CPI
+// file or base period is given.
+func (app *application) restate(cpiFile, base string) error {
+	if cpiFile == "" && base == "" {
+		return nil
+	}
+	if cpiFile == "" {
+		cpiFile = expandPath(app.config.CPI)
+	}
+	if cpiFile == "" {
+		return errors.New("please give a CPI file with -cpi, or set cpi in the config")
+	}
+
+	points, err := loadCPI(cpiFile, app.location(), app.config.MonthFirst)
+	if err != nil {
+		return fmt.Errorf("unable to load CPI: %w", err)
+	}
+	restatement := Restatement{File: cpiFile, points: points}
+
+	restatement.Base = points[len(points)-1].date
+	if base != "" {
+		if restatement.Base, err = app.parseDate(base); err != nil {
+			return err
+		}
+	}
+	restatement.BaseIndex = restatement.indexAt(restatement.Base)
+
+	restatement.apply(*app.transactions)
+	app.restatement = &restatement
+	return nil
+}
+
+// printRestatement says which index amounts were restated with, if any.
+func (app *application) printRestatement() {
+	if app.restatement != nil {
+		fmt.Println(app.restatement)
+		fmt.Println()
+	}
+}
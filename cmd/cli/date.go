@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// now returns the current time. Relative dates such as "today" are worked out
+// from it.
+var now = time.Now
+
+var (
+	isoLayouts        = []string{"2006-01-02", "2006/01/02", "20060102", time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05"}
+	dayFirstLayouts   = []string{"2/1/2006", "2-1-2006", "2.1.2006", "2/1/06", "2-1-06", "2.1.06"}
+	monthFirstLayouts = []string{"1/2/2006", "1-2-2006", "1.2.2006", "1/2/06", "1-2-06", "1.2.06"}
+	namedLayouts      = []string{
+		"2 Jan 2006", "2 January 2006", "2-Jan-2006", "2-Jan-06",
+		"Jan 2 2006", "Jan 2, 2006", "January 2 2006", "January 2, 2006",
+	}
+)
+
+// dateLayouts returns every layout dates are parsed with, in order of
+// preference. Dates such as 05/03/2024 are read day first unless monthFirst
+// is set.
+func dateLayouts(monthFirst bool) []string {
+	first, second := dayFirstLayouts, monthFirstLayouts
+	if monthFirst {
+		first, second = second, first
+	}
+
+	var layouts []string
+	layouts = append(layouts, isoLayouts...)
+	layouts = append(layouts, first...)
+	layouts = append(layouts, second...)
+	return append(layouts, namedLayouts...)
+}
+
+// parseAnyDate parses a date in the first of the layouts it fits, returning
+// its calendar date in the location.
+func parseAnyDate(value string, layouts []string, location *time.Location) (time.Time, bool) {
+	for _, layout := range layouts {
+		if date, err := time.ParseInLocation(layout, value, location); err == nil {
+			return localDate(date, location), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// detectDateLayout returns the layout that parses the most of the dates. When
+// several parse all of them, as when no day is past the 12th, the earliest
+// in the list wins.
+func detectDateLayout(dates []string, layouts []string) string {
+	best, bestCount := layouts[0], -1
+	for _, layout := range layouts {
+		var count int
+		for _, date := range dates {
+			if _, err := time.Parse(layout, date); err == nil {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = layout, count
+		}
+		if count == len(dates) {
+			break
+		}
+	}
+
+	return best
+}
+
+// relativeOffset matches offsets from today such as -30d, +2w, -3m or -1y.
+var relativeOffset = regexp.MustCompile(`^([+-]\d+)([dwmy])$`)
+
+// fiscalYear matches fiscal years such as fy2024, named after the year they
+// end in.
+var fiscalYear = regexp.MustCompile(`^fy(\d{4})$`)
+
+// parseDateRange parses a date, or an expression naming a span of days,
+// returning the first and last day it covers. Besides dates in the configured
+// layout, ISO and common local formats, it understands:
+//
+//	today, yesterday          that day
+//	-30d, +2w, -3m, -1y       that many days, weeks, months or years from today
+//	this-month, last-quarter  the whole week, month, quarter, year or fy
+//	mtd, qtd, ytd, fytd       the start of the period up to today
+//	2024, 2024-03, fy2024     the whole year, month or fiscal year
+func (app *application) parseDateRange(expr string) (time.Time, time.Time, error) {
+	expr = strings.ToLower(strings.TrimSpace(expr))
+	location := app.location()
+	today := localDate(now(), location)
+
+	// Spans are worked out as their first day and the first day after them
+	span := func(start, next time.Time) (time.Time, time.Time, error) {
+		return start, next.AddDate(0, 0, -1), nil
+	}
+	periods := map[string]Period{"month": Monthly, "quarter": Quarterly, "year": Yearly}
+
+	switch expr {
+	case "today":
+		return today, today, nil
+	case "yesterday":
+		yesterday := today.AddDate(0, 0, -1)
+		return yesterday, yesterday, nil
+	case "mtd":
+		return Monthly.start(today), today, nil
+	case "qtd":
+		return Quarterly.start(today), today, nil
+	case "ytd":
+		return Yearly.start(today), today, nil
+	case "fytd":
+		return app.fiscalYearStart(today), today, nil
+	case "this-week", "last-week":
+		// Weeks start on Monday
+		start := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+		if expr == "last-week" {
+			start = start.AddDate(0, 0, -7)
+		}
+		return span(start, start.AddDate(0, 0, 7))
+	case "this-fy", "last-fy":
+		start := app.fiscalYearStart(today)
+		if expr == "last-fy" {
+			start = start.AddDate(-1, 0, 0)
+		}
+		return span(start, start.AddDate(1, 0, 0))
+	}
+
+	if which, name, ok := strings.Cut(expr, "-"); ok && (which == "this" || which == "last") {
+		if period, ok := periods[name]; ok {
+			start := period.start(today)
+			if which == "last" {
+				start = period.start(start.AddDate(0, 0, -1))
+			}
+			return span(start, period.next(start))
+		}
+	}
+
+	if match := relativeOffset.FindStringSubmatch(expr); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		date := today
+		switch match[2] {
+		case "d":
+			date = today.AddDate(0, 0, n)
+		case "w":
+			date = today.AddDate(0, 0, 7*n)
+		case "m":
+			date = today.AddDate(0, n, 0)
+		case "y":
+			date = today.AddDate(n, 0, 0)
+		}
+		return date, date, nil
+	}
+
+	if match := fiscalYear.FindStringSubmatch(expr); match != nil {
+		fy, _ := strconv.Atoi(match[1])
+		start := time.Date(fy, app.fiscalMonth(), 1, 0, 0, 0, 0, location)
+		if start.Month() != time.January {
+			start = start.AddDate(-1, 0, 0)
+		}
+		return span(start, start.AddDate(1, 0, 0))
+	}
+
+	if start, err := time.ParseInLocation("2006", expr, location); err == nil {
+		return span(start, start.AddDate(1, 0, 0))
+	}
+	if start, err := time.ParseInLocation("2006-01", expr, location); err == nil {
+		return span(start, start.AddDate(0, 1, 0))
+	}
+
+	layouts := dateLayouts(app.config.MonthFirst)
+	if app.config.DateFormat != "" {
+		layouts = append([]string{app.config.DateFormat}, layouts...)
+	}
+	if date, ok := parseAnyDate(expr, layouts, location); ok {
+		return date, date, nil
+	}
+
+	return time.Time{}, time.Time{}, fmt.Errorf("unable to parse date %q: use a date such as 2024-03-31 or 31-03-2024, or an expression such as -30d, last-month or ytd", expr)
+}
+
+// parseDate parses a date or expression, returning the first day it covers.
+func (app *application) parseDate(expr string) (time.Time, error) {
+	start, _, err := app.parseDateRange(expr)
+	return start, err
+}
+
+// parseEndDate parses a date or expression, returning the last day it
+// covers, so that "-ld last-month" includes the whole month.
+func (app *application) parseEndDate(expr string) (time.Time, error) {
+	_, end, err := app.parseDateRange(expr)
+	return end, err
+}
+
+// fiscalMonth returns the month the fiscal year starts in, January unless
+// the config says otherwise.
+func (app *application) fiscalMonth() time.Month {
+	month := time.Month(app.config.FiscalYearStart)
+	if month < time.January || month > time.December {
+		return time.January
+	}
+	return month
+}
+
+// fiscalYearStart returns the first day of the fiscal year containing t.
+func (app *application) fiscalYearStart(t time.Time) time.Time {
+	start := time.Date(t.Year(), app.fiscalMonth(), 1, 0, 0, 0, 0, t.Location())
+	if start.After(t) {
+		start = start.AddDate(-1, 0, 0)
+	}
+
+	return start
+}
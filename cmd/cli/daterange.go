@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateRange is a span of calendar days from Start to End. Start is always
+// included. End is included too unless Exclusive is set, which suits ranges
+// built from the start of one period and the start of the next. A zero Start
+// or End leaves that side of the range open.
+//
+// Days are compared by their calendar date alone, so the time of day and
+// location of each date don't matter.
+type DateRange struct {
+	Start     time.Time
+	End       time.Time
+	Exclusive bool
+}
+
+// inclusiveRange returns the range from start to end, including both days.
+func inclusiveRange(start, end time.Time) DateRange {
+	return DateRange{Start: start, End: end}
+}
+
+// calendarDay returns the calendar date of t as a number that orders days,
+// such as 20240331.
+func calendarDay(t time.Time) int {
+	year, month, day := t.Date()
+	return year*10000 + int(month)*100 + day
+}
+
+// localDate returns the calendar date of t in the location, at midnight.
+func localDate(t time.Time, location *time.Location) time.Time {
+	year, month, day := t.In(location).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, location)
+}
+
+// Contains reports whether t falls on one of the range's days.
+func (r DateRange) Contains(t time.Time) bool {
+	day := calendarDay(t)
+	if !r.Start.IsZero() && day < calendarDay(r.Start) {
+		return false
+	}
+	if r.End.IsZero() {
+		return true
+	}
+	if r.Exclusive {
+		return day < calendarDay(r.End)
+	}
+	return day <= calendarDay(r.End)
+}
+
+// Last returns the last day in the range, or the zero time when the range has
+// no end.
+func (r DateRange) Last() time.Time {
+	if r.Exclusive && !r.End.IsZero() {
+		return r.End.AddDate(0, 0, -1)
+	}
+	return r.End
+}
+
+// Filter returns the transactions that fall within the range.
+func (r DateRange) Filter(transactions Transactions) Transactions {
+	var filtered Transactions
+	for _, transaction := range transactions {
+		if r.Contains(transaction.Date) {
+			filtered = append(filtered, transaction)
+		}
+	}
+
+	return filtered
+}
+
+func (r DateRange) String() string {
+	format := func(t time.Time) string {
+		if t.IsZero() {
+			return "..."
+		}
+		return t.Format("02-01-2006")
+	}
+
+	return fmt.Sprintf("%s to %s", format(r.Start), format(r.Last()))
+}
+
+// transactionsRange returns the range from the earliest to the latest
+// transaction, whatever order they are in.
+func transactionsRange(transactions Transactions) DateRange {
+	if len(transactions) == 0 {
+		return DateRange{}
+	}
+
+	earliest, latest := transactions[0].Date, transactions[0].Date
+	for _, transaction := range transactions {
+		if calendarDay(transaction.Date) < calendarDay(earliest) {
+			earliest = transaction.Date
+		}
+		if calendarDay(transaction.Date) > calendarDay(latest) {
+			latest = transaction.Date
+		}
+	}
+
+	return inclusiveRange(earliest, latest)
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestDateRangeContains(t *testing.T) {
+	sydney := time.FixedZone("AEST", 10*60*60)
+
+	tests := []struct {
+		name      string
+		dateRange DateRange
+		date      time.Time
+		want      bool
+	}{
+		{"start day", inclusiveRange(date(2024, 3, 1), date(2024, 3, 31)), date(2024, 3, 1), true},
+		{"end day", inclusiveRange(date(2024, 3, 1), date(2024, 3, 31)), date(2024, 3, 31), true},
+		{"day before start", inclusiveRange(date(2024, 3, 1), date(2024, 3, 31)), date(2024, 2, 29), false},
+		{"day after end", inclusiveRange(date(2024, 3, 1), date(2024, 3, 31)), date(2024, 4, 1), false},
+		{"late on end day", inclusiveRange(date(2024, 3, 1), date(2024, 3, 31)), time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC), true},
+		{"single day", inclusiveRange(date(2024, 3, 5), date(2024, 3, 5)), date(2024, 3, 5), true},
+		{"exclusive end day", DateRange{Start: date(2024, 3, 1), End: date(2024, 4, 1), Exclusive: true}, date(2024, 4, 1), false},
+		{"exclusive day before end", DateRange{Start: date(2024, 3, 1), End: date(2024, 4, 1), Exclusive: true}, date(2024, 3, 31), true},
+		{"open start", DateRange{End: date(2024, 3, 31)}, date(1999, 1, 1), true},
+		{"open end", DateRange{Start: date(2024, 3, 1)}, date(2099, 1, 1), true},
+		{"open end before start", DateRange{Start: date(2024, 3, 1)}, date(2024, 2, 29), false},
+		{"unbounded", DateRange{}, date(2024, 3, 1), true},
+		{"calendar date in another location", inclusiveRange(date(2024, 3, 1), date(2024, 3, 31)), time.Date(2024, 4, 1, 0, 0, 0, 0, sydney), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dateRange.Contains(tt.date); got != tt.want {
+				t.Errorf("%s Contains(%s) = %t, want %t", tt.dateRange, tt.date.Format("02-01-2006 15:04 MST"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateRangeLast(t *testing.T) {
+	tests := []struct {
+		name      string
+		dateRange DateRange
+		want      time.Time
+	}{
+		{"inclusive", inclusiveRange(date(2024, 3, 1), date(2024, 3, 31)), date(2024, 3, 31)},
+		{"exclusive", DateRange{Start: date(2024, 2, 1), End: date(2024, 3, 1), Exclusive: true}, date(2024, 2, 29)},
+		{"open", DateRange{Start: date(2024, 3, 1), Exclusive: true}, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dateRange.Last(); !got.Equal(tt.want) {
+				t.Errorf("Last() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodRanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		start     time.Time
+		end       time.Time
+		period    Period
+		wantCount int
+		wantFirst DateRange
+		wantLast  time.Time
+	}{
+		{"months across a year end", date(2023, 11, 15), date(2024, 1, 2), Monthly, 3, DateRange{Start: date(2023, 11, 1), End: date(2023, 12, 1), Exclusive: true}, date(2024, 1, 31)},
+		{"leap february", date(2024, 2, 29), date(2024, 2, 29), Monthly, 1, DateRange{Start: date(2024, 2, 1), End: date(2024, 3, 1), Exclusive: true}, date(2024, 2, 29)},
+		{"end on a period's first day", date(2024, 1, 31), date(2024, 2, 1), Monthly, 2, DateRange{Start: date(2024, 1, 1), End: date(2024, 2, 1), Exclusive: true}, date(2024, 2, 29)},
+		{"quarters", date(2024, 2, 10), date(2024, 7, 1), Quarterly, 3, DateRange{Start: date(2024, 1, 1), End: date(2024, 4, 1), Exclusive: true}, date(2024, 9, 30)},
+		{"years", date(2023, 12, 31), date(2024, 1, 1), Yearly, 2, DateRange{Start: date(2023, 1, 1), End: date(2024, 1, 1), Exclusive: true}, date(2024, 12, 31)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := periodRanges(tt.start, tt.end, tt.period)
+			if len(got) != tt.wantCount {
+				t.Fatalf("got %d ranges, want %d", len(got), tt.wantCount)
+			}
+			if got[0] != tt.wantFirst {
+				t.Errorf("first range = %s, want %s", got[0], tt.wantFirst)
+			}
+			if last := got[len(got)-1].Last(); !last.Equal(tt.wantLast) {
+				t.Errorf("last day = %s, want %s", last.Format("02-01-2006"), tt.wantLast.Format("02-01-2006"))
+			}
+			for i := 1; i < len(got); i++ {
+				if !got[i].Start.Equal(got[i-1].End) {
+					t.Errorf("range %d starts %s, want %s", i, got[i].Start, got[i-1].End)
+				}
+			}
+		})
+	}
+}
+
+func TestGetDateRanges(t *testing.T) {
+	tests := []struct {
+		name         string
+		dates        []time.Time
+		filterByDate bool
+		wantCount    int
+	}{
+		{"whole span newest first", []time.Time{date(2024, 3, 31), date(2024, 3, 15), date(2024, 3, 1)}, false, 1},
+		{"whole span unsorted", []time.Time{date(2024, 3, 15), date(2024, 3, 31), date(2024, 3, 1)}, false, 1},
+		{"one day", []time.Time{date(2024, 3, 1)}, true, 1},
+		{"exactly four weeks", []time.Time{date(2024, 3, 28), date(2024, 3, 1)}, true, 1},
+		{"four weeks and a day", []time.Time{date(2024, 3, 29), date(2024, 3, 1)}, true, 2},
+		{"several periods", []time.Time{date(2024, 6, 30), date(2024, 1, 1)}, true, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var transactions Transactions
+			for _, d := range tt.dates {
+				transactions = append(transactions, Transaction{Date: d, Amount: -1, Type: Expense})
+			}
+			app := &application{transactions: &transactions, config: &Config{}}
+
+			dateRanges := app.getDateRanges(tt.filterByDate)
+			if len(dateRanges) != tt.wantCount {
+				t.Fatalf("got %d ranges, want %d", len(dateRanges), tt.wantCount)
+			}
+
+			// Every transaction, including the first and last, is in exactly
+			// one range
+			for _, transaction := range transactions {
+				var matches int
+				for _, dateRange := range dateRanges {
+					if dateRange.Contains(transaction.Date) {
+						matches++
+					}
+				}
+				if matches != 1 {
+					t.Errorf("%s is in %d ranges, want 1", transaction.Date.Format("02-01-2006"), matches)
+				}
+			}
+		})
+	}
+}
+
+func TestDateFlagBoundaries(t *testing.T) {
+	transactions := Transactions{
+		{Date: date(2024, 3, 31), Amount: -1},
+		{Date: date(2024, 3, 15), Amount: -1},
+		{Date: date(2024, 3, 1), Amount: -1},
+		{Date: date(2024, 2, 29), Amount: -1},
+	}
+
+	tests := []struct {
+		name   string
+		filter func(app *application) error
+		want   int
+	}{
+		{"-gd keeps its own day", func(app *application) error { return app.handleGreaterDateFlag("01-03-2024") }, 3},
+		{"-ld keeps its own day", func(app *application) error { return app.handleLesserDateFlag("01-03-2024") }, 2},
+		{"-md keeps both days", func(app *application) error { return app.handleMiddleDateFlag([]string{"01-03-2024", "31-03-2024"}) }, 3},
+		{"-md with a month", func(app *application) error { return app.handleMiddleDateFlag([]string{"2024-03"}) }, 3},
+		{"-ld with a month keeps its last day", func(app *application) error { return app.handleLesserDateFlag("2024-03") }, 4},
+		{"-gd with a month starts on its first day", func(app *application) error { return app.handleGreaterDateFlag("2024-03") }, 3},
+		{"-md on one day", func(app *application) error { return app.handleMiddleDateFlag([]string{"15-03-2024", "15-03-2024"}) }, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered := append(Transactions{}, transactions...)
+			app := &application{transactions: &filtered, config: &Config{}, timezone: time.UTC}
+			if err := tt.filter(app); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(*app.transactions); got != tt.want {
+				t.Errorf("got %d transactions, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	defer func(original func() time.Time) { now = original }(now)
+	sydney := time.FixedZone("AEST", 10*60*60)
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		config     Config
+		location   *time.Location
+		expr       string
+		wantStart  time.Time
+		wantEnd    time.Time
+		wantErr    bool
+		inLocation bool
+	}{
+		{name: "iso", expr: "2024-03-31", wantStart: date(2024, 3, 31), wantEnd: date(2024, 3, 31)},
+		{name: "day first", expr: "05-03-2024", wantStart: date(2024, 3, 5), wantEnd: date(2024, 3, 5)},
+		{name: "month first", config: Config{MonthFirst: true}, expr: "05/03/2024", wantStart: date(2024, 5, 3), wantEnd: date(2024, 5, 3)},
+		{name: "configured layout", config: Config{DateFormat: "2006.01.02"}, expr: "2024.03.05", wantStart: date(2024, 3, 5), wantEnd: date(2024, 3, 5)},
+		{name: "named month", expr: "5 Mar 2024", wantStart: date(2024, 3, 5), wantEnd: date(2024, 3, 5)},
+		{name: "today", now: date(2024, 3, 15), expr: "today", wantStart: date(2024, 3, 15), wantEnd: date(2024, 3, 15)},
+		{name: "days ago", now: date(2024, 3, 15), expr: "-30d", wantStart: date(2024, 2, 14), wantEnd: date(2024, 2, 14)},
+		{name: "last month in january", now: date(2024, 1, 10), expr: "last-month", wantStart: date(2023, 12, 1), wantEnd: date(2023, 12, 31)},
+		{name: "last month in march", now: date(2024, 3, 31), expr: "last-month", wantStart: date(2024, 2, 1), wantEnd: date(2024, 2, 29)},
+		{name: "this quarter", now: date(2024, 5, 20), expr: "this-quarter", wantStart: date(2024, 4, 1), wantEnd: date(2024, 6, 30)},
+		{name: "last week", now: date(2024, 3, 13), expr: "last-week", wantStart: date(2024, 3, 4), wantEnd: date(2024, 3, 10)},
+		{name: "ytd", now: date(2024, 3, 15), expr: "ytd", wantStart: date(2024, 1, 1), wantEnd: date(2024, 3, 15)},
+		{name: "calendar fiscal year", expr: "fy2024", wantStart: date(2024, 1, 1), wantEnd: date(2024, 12, 31)},
+		{name: "july fiscal year", config: Config{FiscalYearStart: 7}, expr: "fy2024", wantStart: date(2023, 7, 1), wantEnd: date(2024, 6, 30)},
+		{name: "this fiscal year", now: date(2024, 3, 15), config: Config{FiscalYearStart: 7}, expr: "this-fy", wantStart: date(2023, 7, 1), wantEnd: date(2024, 6, 30)},
+		{name: "month", expr: "2024-02", wantStart: date(2024, 2, 1), wantEnd: date(2024, 2, 29)},
+		{name: "year", expr: "2023", wantStart: date(2023, 1, 1), wantEnd: date(2023, 12, 31)},
+		{name: "today in the configured timezone", now: time.Date(2024, 3, 31, 20, 0, 0, 0, time.UTC), location: sydney, expr: "today", wantStart: date(2024, 4, 1), wantEnd: date(2024, 4, 1), inLocation: true},
+		{name: "invalid", expr: "next-tuesday", wantErr: true},
+		{name: "invalid date", expr: "31-02-2024", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = func() time.Time { return tt.now }
+			location := tt.location
+			if location == nil {
+				location = time.UTC
+			}
+			config := tt.config
+			app := &application{config: &config, timezone: location}
+
+			start, end, err := app.parseDateRange(tt.expr)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseDateRange(%q) = %s, want an error", tt.expr, inclusiveRange(start, end))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if calendarDay(start) != calendarDay(tt.wantStart) || calendarDay(end) != calendarDay(tt.wantEnd) {
+				t.Errorf("parseDateRange(%q) = %s, want %s", tt.expr, inclusiveRange(start, end), inclusiveRange(tt.wantStart, tt.wantEnd))
+			}
+			if tt.inLocation && start.Location() != location {
+				t.Errorf("start is in %s, want %s", start.Location(), location)
+			}
+		})
+	}
+}
+
+func TestImportCSVDates(t *testing.T) {
+	sydney := time.FixedZone("AEST", 10*60*60)
+
+	tests := []struct {
+		name      string
+		csv       string
+		profile   ImportProfile
+		location  *time.Location
+		wantDates []time.Time
+		wantErr   bool
+	}{
+		{
+			name:      "day first when every day could be a month",
+			csv:       "05/03/2024,-1,A\n01/03/2024,-1,B\n",
+			wantDates: []time.Time{date(2024, 3, 5), date(2024, 3, 1)},
+		},
+		{
+			name:      "month first detected from a later row",
+			csv:       "03/05/2024,-1,A\n03/01/2024,-1,B\n02/28/2024,-1,C\n",
+			wantDates: []time.Time{date(2024, 3, 5), date(2024, 3, 1), date(2024, 2, 28)},
+		},
+		{
+			name:      "month first preferred",
+			csv:       "03/05/2024,-1,A\n",
+			profile:   ImportProfile{MonthFirst: true},
+			wantDates: []time.Time{date(2024, 3, 5)},
+		},
+		{
+			name:      "header row skipped",
+			csv:       "Date,Amount,Description\n2024-03-05,-1,A\n",
+			wantDates: []time.Time{date(2024, 3, 5)},
+		},
+		{
+			name:      "timestamps take their date in the timezone",
+			csv:       "2024-03-31T20:00:00Z,-1,A\n2024-03-31T08:00:00Z,-1,B\n",
+			location:  sydney,
+			wantDates: []time.Time{date(2024, 4, 1), date(2024, 3, 31)},
+		},
+		{
+			name:      "dates without a time keep their day in the timezone",
+			csv:       "31/03/2024,-1,A\n",
+			location:  sydney,
+			wantDates: []time.Time{date(2024, 3, 31)},
+		},
+		{
+			name:    "bad date",
+			csv:     "05/03/2024,-1,A\n45/03/2024,-1,B\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad amount",
+			csv:     "05/03/2024,one,A\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "bank.csv")
+			if err := os.WriteFile(filename, []byte(tt.csv), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			profile := defaultProfile
+			profile.MonthFirst = tt.profile.MonthFirst
+			profile.location = tt.location
+			if profile.location == nil {
+				profile.location = time.UTC
+			}
+
+			transactions, err := importCSV(filename, profile)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(transactions) != len(tt.wantDates) {
+				t.Fatalf("got %d transactions, want %d", len(transactions), len(tt.wantDates))
+			}
+			for i, transaction := range transactions {
+				if calendarDay(transaction.Date) != calendarDay(tt.wantDates[i]) {
+					t.Errorf("transaction %d is on %s, want %s", i, transaction.Date.Format("02-01-2006"), tt.wantDates[i].Format("02-01-2006"))
+				}
+				if transaction.Date.Location() != profile.location {
+					t.Errorf("transaction %d is in %s, want %s", i, transaction.Date.Location(), profile.location)
+				}
+			}
+		})
+	}
+}
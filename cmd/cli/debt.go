@@ -0,0 +1,335 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxPayoffMonths stops simulations of debts that are never paid off.
+const maxPayoffMonths = 1200
+
+// Payoff strategies, which decide the debt any money left after minimum
+// payments goes to.
+const (
+	Avalanche = "avalanche"
+	Snowball  = "snowball"
+	Custom    = "custom"
+	// Actual pays each debt what has been paid into its account on average,
+	// with nothing rolling over between debts.
+	Actual = "actual"
+)
+
+// Debt is a loan or card to plan paying off. A debt linked to an account
+// takes its balance from the account.
+type Debt struct {
+	Balance float64 `yaml:"balance"`
+	// APR is the yearly interest rate as a percentage.
+	APR     float64 `yaml:"apr"`
+	Minimum float64 `yaml:"minimum"`
+	Account string  `yaml:"account"`
+	// Priority orders debts for the custom strategy, lowest first. Debts
+	// without one come last.
+	Priority int `yaml:"priority"`
+
+	name string
+	// payment is what the actual strategy pays each month.
+	payment float64
+}
+
+// DebtPayoff is one debt's outcome under a strategy.
+type DebtPayoff struct {
+	// Months is how many months the debt takes to pay off, 0 if it never is.
+	Months int
+}
+
+// PayoffPlan is the outcome of paying off every debt with one strategy.
+type PayoffPlan struct {
+	Strategy string
+	Debts    map[string]DebtPayoff
+	// Schedule holds every debt's balance at the end of each month.
+	Schedule []map[string]float64
+	Interest float64
+	Paid     float64
+	// Months until the last debt is paid off, 0 if one never is.
+	Months int
+}
+
+// debtOrder sorts debts into the order a strategy pays extra towards them,
+// given their current balances.
+func debtOrder(debts []Debt, balances map[string]float64, strategy string) []Debt {
+	ordered := append([]Debt{}, debts...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		switch strategy {
+		case Avalanche:
+			if a.APR != b.APR {
+				return a.APR > b.APR
+			}
+		case Snowball:
+			if balances[a.name] != balances[b.name] {
+				return balances[a.name] < balances[b.name]
+			}
+		case Custom:
+			if a.Priority != b.Priority {
+				return b.Priority == 0 || (a.Priority != 0 && a.Priority < b.Priority)
+			}
+		}
+		return a.name < b.name
+	})
+
+	return ordered
+}
+
+// simulatePayoff pays down the debts month by month. Interest is added first,
+// then every debt gets its minimum and whatever is left of the budget goes to
+// debts in the strategy's order, so the minimum of a paid off debt rolls over
+// to the next.
+func simulatePayoff(debts []Debt, budget float64, strategy string) PayoffPlan {
+	plan := PayoffPlan{Strategy: strategy, Debts: make(map[string]DebtPayoff)}
+	balances := make(map[string]float64)
+	for _, debt := range debts {
+		balances[debt.name] = debt.Balance
+		plan.Debts[debt.name] = DebtPayoff{}
+	}
+
+	owing := func() bool {
+		for _, balance := range balances {
+			if balance > 0.005 {
+				return true
+			}
+		}
+		return false
+	}
+
+	for month := 1; owing() && month <= maxPayoffMonths; month++ {
+		left := budget
+		for _, debt := range debts {
+			if balances[debt.name] <= 0.005 {
+				continue
+			}
+			interest := balances[debt.name] * debt.APR / 100 / 12
+			balances[debt.name] += interest
+			plan.Interest += interest
+
+			payment := debt.Minimum
+			if strategy == Actual {
+				payment = debt.payment
+			}
+			payment = math.Min(payment, balances[debt.name])
+			balances[debt.name] -= payment
+			left -= payment
+			plan.Paid += payment
+		}
+
+		if strategy != Actual {
+			for _, debt := range debtOrder(debts, balances, strategy) {
+				if left <= 0.005 {
+					break
+				}
+				payment := math.Min(left, balances[debt.name])
+				balances[debt.name] -= payment
+				left -= payment
+				plan.Paid += payment
+			}
+		}
+
+		snapshot := make(map[string]float64)
+		for _, debt := range debts {
+			snapshot[debt.name] = balances[debt.name]
+			payoff := plan.Debts[debt.name]
+			if payoff.Months == 0 && balances[debt.name] <= 0.005 {
+				payoff.Months = month
+				plan.Debts[debt.name] = payoff
+			}
+		}
+		plan.Schedule = append(plan.Schedule, snapshot)
+	}
+
+	if !owing() {
+		for _, payoff := range plan.Debts {
+			if payoff.Months > plan.Months {
+				plan.Months = payoff.Months
+			}
+		}
+	}
+
+	return plan
+}
+
+// loadDebts returns the debts in the config, with balances and actual
+// payments taken from linked accounts.
+func (app *application) loadDebts(transactions Transactions) ([]Debt, error) {
+	var balances map[string]RunningBalance
+	if transactions != nil {
+		balances = app.accountBalances()
+	}
+	var debts []Debt
+	for name, debt := range app.config.Debts {
+		debt.name = name
+		debt.payment = debt.Minimum
+		if debt.Account != "" {
+			running, ok := balances[debt.Account]
+			if !ok {
+				return nil, fmt.Errorf("debt %q: no account named %q", name, debt.Account)
+			}
+			balance := running.Opening
+			if len(running.Checks) > 0 {
+				balance = running.Checks[len(running.Checks)-1].Computed
+			}
+			// Money owed on a liability account is a negative balance
+			debt.Balance = -balance
+
+			var payments Transactions
+			for _, transaction := range transactions {
+				if transaction.Account == debt.Account && transaction.Type == Income {
+					payments = append(payments, transaction)
+				}
+			}
+			if span := transactionsRange(payments); !span.Start.IsZero() {
+				months := math.Max((span.End.Sub(span.Start).Hours()/24+1)/daysPerMonth, 1)
+				total := 0.0
+				for _, payment := range payments {
+					total += payment.Amount
+				}
+				debt.payment = total / months
+			}
+		}
+		if debt.Balance < 0 || debt.APR < 0 || debt.Minimum < 0 {
+			return nil, fmt.Errorf("debt %q: balance, apr and minimum can't be negative", name)
+		}
+
+		debts = append(debts, debt)
+	}
+
+	sort.Slice(debts, func(i, j int) bool {
+		return debts[i].name < debts[j].name
+	})
+	return debts, nil
+}
+
+// payoffDate returns when a debt paid off after some months will be clear.
+func payoffDate(today time.Time, months int) string {
+	if months == 0 {
+		return "never"
+	}
+	return today.AddDate(0, months, 0).Format("01-2006")
+}
+
+// printPayoffPlans prints every debt's payoff date under each strategy, then
+// compares the strategies' totals.
+func (app *application) printPayoffPlans(debts []Debt, plans []PayoffPlan, today time.Time) {
+	fmt.Printf("%-20s  %12s  %7s  %10s", "Debt", "Balance", "APR", "Minimum")
+	for _, plan := range plans {
+		fmt.Printf("  %10s", plan.Strategy)
+	}
+	fmt.Println()
+	for _, debt := range debts {
+		fmt.Printf("%-20s  %12.2f  %6.2f%%  %10.2f", fitLabel(debt.name, 20), debt.Balance, debt.APR, debt.Minimum)
+		for _, plan := range plans {
+			fmt.Printf("  %10s", payoffDate(today, plan.Debts[debt.name].Months))
+		}
+		fmt.Println()
+	}
+
+	fmt.Println()
+	fmt.Printf("%-10s  %6s  %-10s  %12s  %12s\n", "Strategy", "Months", "Debt free", "Interest", "Total paid")
+	for _, plan := range plans {
+		months := "-"
+		if plan.Months > 0 {
+			months = fmt.Sprint(plan.Months)
+		}
+		fmt.Printf("%-10s  %6s  %-10s  %12.2f  %12.2f\n", plan.Strategy, months, payoffDate(today, plan.Months), plan.Interest, plan.Paid)
+	}
+}
+
+// printSchedule prints every debt's balance at the end of each month.
+func printSchedule(debts []Debt, plan PayoffPlan, today time.Time) {
+	fmt.Printf("%-7s", "Month")
+	for _, debt := range debts {
+		fmt.Printf("  %12.12s", debt.name)
+	}
+	fmt.Println()
+	for i, balances := range plan.Schedule {
+		fmt.Printf("%-7s", today.AddDate(0, i+1, 0).Format("01-2006"))
+		for _, debt := range debts {
+			fmt.Printf("  %12.2f", balances[debt.name])
+		}
+		fmt.Println()
+	}
+}
+
+// debtsCommand compares strategies for paying off the debts in the config
+// with a monthly budget.
+func (app *application) debtsCommand(args []string) error {
+	fs, src := newFlagSet("debts")
+	budget := fs.Float64("budget", 0, "total to put towards debts each month.\nDefaults to debt_budget from the config")
+	schedule := fs.String("schedule", "", "print the month by month balances of a strategy:\navalanche, snowball, custom or actual")
+	fs.Parse(args)
+
+	if len(app.config.Debts) == 0 {
+		return errors.New("no debts found: add them under debts in the config")
+	}
+	if *budget == 0 {
+		*budget = app.config.DebtBudget
+	}
+
+	// Debts linked to accounts need the ledger for balances and payments
+	linked := false
+	for _, debt := range app.config.Debts {
+		linked = linked || debt.Account != ""
+	}
+	var transactions Transactions
+	if linked {
+		if err := app.load(src); err != nil {
+			return err
+		}
+		transactions = *app.transactions
+	}
+
+	debts, err := app.loadDebts(transactions)
+	if err != nil {
+		return err
+	}
+
+	minimums := 0.0
+	custom := false
+	for _, debt := range debts {
+		minimums += debt.Minimum
+		custom = custom || debt.Priority != 0
+	}
+	if *budget < minimums {
+		return fmt.Errorf("the budget of %s doesn't cover the minimum payments of %s", app.money(*budget), app.money(minimums))
+	}
+
+	strategies := []string{Avalanche, Snowball}
+	if custom {
+		strategies = append(strategies, Custom)
+	}
+	if linked {
+		strategies = append(strategies, Actual)
+	}
+
+	var plans []PayoffPlan
+	for _, strategy := range strategies {
+		plans = append(plans, simulatePayoff(debts, *budget, strategy))
+	}
+
+	today := localDate(now(), app.location())
+	if *schedule != "" {
+		for _, plan := range plans {
+			if plan.Strategy == strings.ToLower(*schedule) {
+				printSchedule(debts, plan, today)
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown strategy %q: use %s", *schedule, strings.Join(strategies, ", "))
+	}
+
+	fmt.Printf("Paying %s a month\n\n", app.money(*budget))
+	app.printPayoffPlans(debts, plans, today)
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"strings"
+)
+
+// Edit is a change made to an imported transaction. Edits are kept apart from
+// the bank's export, keyed by transaction ID, so they survive re-importing it.
+type Edit struct {
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Excluded bool     `json:"excluded,omitempty"`
+	Splits   []Split  `json:"splits,omitempty"`
+	// Reimburse names who is expected to pay an expense back.
+	Reimburse string `json:"reimburse,omitempty"`
+	// Reimburses links an incoming payment to the expenses it pays back.
+	Reimburses []Reimbursement `json:"reimburses,omitempty"`
+	// Sharing divides an expense between people in the household.
+	Sharing *Sharing `json:"sharing,omitempty"`
+	// Settlement marks a payment between people settling shared expenses.
+	Settlement *Settlement `json:"settlement,omitempty"`
+}
+
+// Split is one part of a transaction divided between categories. Any amount
+// not covered by the splits stays with the original transaction.
+type Split struct {
+	Amount   float64 `json:"amount"`
+	Category string  `json:"category,omitempty"`
+}
+
+// Reimbursement is the part of an incoming payment that pays back one
+// expense.
+type Reimbursement struct {
+	ID     string  `json:"id"`
+	Amount float64 `json:"amount"`
+}
+
+type Edits map[string]Edit
+
+func (e Edit) empty() bool {
+	return e.Category == "" && len(e.Tags) == 0 && !e.Excluded && len(e.Splits) == 0 &&
+		e.Reimburse == "" && len(e.Reimburses) == 0 && e.Sharing == nil && e.Settlement == nil
+}
+
+// loadEdits reads edits from a JSON file. A missing file has no edits.
+func loadEdits(filename string) (Edits, error) {
+	edits := make(Edits)
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return edits, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &edits); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return edits, nil
+}
+
+// saveEdits writes edits to a JSON file, dropping any that no longer change
+// anything.
+func saveEdits(filename string, edits Edits) error {
+	for id, edit := range edits {
+		if edit.empty() {
+			delete(edits, id)
+		}
+	}
+
+	data, err := json.MarshalIndent(edits, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, data, 0o644)
+}
+
+// assignIDs gives every transaction an ID derived from its contents, so the
+// same row gets the same ID each time the file is imported. Identical rows
+// are told apart by how many came before them.
+func assignIDs(transactions Transactions) {
+	occurrences := make(map[string]int)
+	for i := range transactions {
+		transaction := &transactions[i]
+		key := fmt.Sprintf("%s|%.2f|%s|%s", transaction.Date.Format("2006-01-02"), transaction.Amount, transaction.Description, transaction.Account)
+		occurrences[key]++
+
+		sum := sha1.Sum([]byte(fmt.Sprintf("%s|%d", key, occurrences[key])))
+		transaction.ID = hex.EncodeToString(sum[:])[:10]
+	}
+}
+
+// applyEdits returns the transactions with edits applied. Split transactions
+// are replaced by one transaction per split, and excluded transactions are
+// dropped unless keepExcluded is set.
+func applyEdits(transactions Transactions, edits Edits, keepExcluded bool) Transactions {
+	// How much of each expense has been paid back, across every payment
+	reimbursed := make(map[string]float64)
+	for _, edit := range edits {
+		for _, reimbursement := range edit.Reimburses {
+			reimbursed[reimbursement.ID] += reimbursement.Amount
+		}
+	}
+
+	var edited Transactions
+	for _, transaction := range transactions {
+		edit, ok := edits[transaction.ID]
+		if !ok {
+			edited = append(edited, transaction)
+			continue
+		}
+
+		if edit.Excluded && !keepExcluded {
+			continue
+		}
+
+		if edit.Category != "" {
+			transaction.Category = edit.Category
+		}
+		transaction.Tags = addTags(append([]string{}, transaction.Tags...), edit.Tags...)
+		transaction.Excluded = edit.Excluded
+		transaction.Reimburse = edit.Reimburse
+		transaction.Sharing = edit.Sharing
+		transaction.Settlement = edit.Settlement
+		for _, reimbursement := range edit.Reimburses {
+			transaction.Reimbursement += reimbursement.Amount
+		}
+
+		if len(edit.Splits) == 0 {
+			edited = append(edited, transaction)
+			continue
+		}
+
+		parent := transaction.Amount
+		remainder := transaction.Amount
+		for i, split := range edit.Splits {
+			part := transaction
+			part.ID = fmt.Sprintf("%s/%d", transaction.ID, i+1)
+			part.Amount = split.Amount
+			part.Type = transactionType(split.Amount)
+			part.Sharing = transaction.Sharing.part(split.Amount / parent)
+			if split.Category != "" {
+				part.Category = split.Category
+			}
+			edited = append(edited, part)
+			remainder -= split.Amount
+		}
+
+		if math.Abs(remainder) >= 0.005 {
+			transaction.Sharing = transaction.Sharing.part(remainder / parent)
+			transaction.Amount = remainder
+			transaction.Type = transactionType(remainder)
+			edited = append(edited, transaction)
+		}
+	}
+
+	for i := range edited {
+		edited[i].Reimbursed = reimbursed[edited[i].ID]
+	}
+
+	return edited
+}
+
+// splitParent returns the ID of the transaction a split was made from.
+func splitParent(id string) string {
+	parent, _, _ := strings.Cut(id, "/")
+	return parent
+}
+
+func transactionType(amount float64) TransactionType {
+	if amount < 0 {
+		return Expense
+	}
+	return Income
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// maxFIREYears is how far projections run before giving up.
+const maxFIREYears = 100
+
+// FIREConfig holds the assumptions projections use when no flags are given.
+// Rates are yearly percentages.
+type FIREConfig struct {
+	Assets         float64 `yaml:"assets"`
+	Return         float64 `yaml:"return"`
+	Inflation      float64 `yaml:"inflation"`
+	WithdrawalRate float64 `yaml:"withdrawal_rate"`
+	// Volatility is the standard deviation of yearly returns in the Monte
+	// Carlo simulation.
+	Volatility float64 `yaml:"volatility"`
+}
+
+// orDefault returns value, or fallback when value is zero.
+func orDefault(value, fallback float64) float64 {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
+
+// FIREPlan is a starting point for projections, in today's dollars.
+type FIREPlan struct {
+	Assets   float64
+	Savings  float64
+	Expenses float64
+	// Target is the assets needed to live off the withdrawal rate.
+	Target float64
+}
+
+// realRate returns a nominal yearly return less inflation.
+func realRate(nominal, inflation float64) float64 {
+	return (1+nominal/100)/(1+inflation/100) - 1
+}
+
+// yearsToFI grows the assets month by month, adding savings each month,
+// until they reach the target. returns gives the real return of each year.
+// It returns the years taken, or -1 if the target isn't reached, and the
+// assets after horizon years.
+func (p FIREPlan) yearsToFI(returns func(year int) float64, horizon int) (float64, float64) {
+	assets := p.Assets
+	years := -1.0
+	atHorizon := assets
+	if assets >= p.Target {
+		years = 0
+	}
+
+	for year := 0; year < maxFIREYears && (years < 0 || year < horizon); year++ {
+		monthly := math.Pow(1+returns(year), 1.0/12) - 1
+		for month := 1; month <= 12; month++ {
+			assets = assets*(1+monthly) + p.Savings/12
+			if years < 0 && assets >= p.Target {
+				years = float64(year) + float64(month)/12
+			}
+		}
+		if year+1 == horizon {
+			atHorizon = assets
+		}
+	}
+
+	return years, atHorizon
+}
+
+// percentile returns the pth percentile of sorted values, by nearest rank.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// fiDate returns the month financial independence is reached, or "never".
+func fiDate(today time.Time, years float64) string {
+	if years < 0 {
+		return "never"
+	}
+	return today.AddDate(0, int(math.Round(years*12)), 0).Format("01-2006")
+}
+
+// fireCommand projects how long until the assets can cover expenses at a
+// withdrawal rate, from the ledger's income and expenses.
+func (app *application) fireCommand(args []string) error {
+	config := app.config.FIRE
+	fs, src := newFlagSet("fire")
+	filters := addFilterFlags(fs)
+	assets := fs.Float64("assets", config.Assets, "invested assets today.\nDefaults to the value of the investments in the config")
+	expected := fs.Float64("return", orDefault(config.Return, 7), "expected yearly return, as a percentage")
+	inflation := fs.Float64("inflation", orDefault(config.Inflation, 2.5), "expected yearly inflation, as a percentage")
+	withdrawal := fs.Float64("withdrawal", orDefault(config.WithdrawalRate, 4), "yearly withdrawal rate in retirement, as a percentage")
+	volatility := fs.Float64("volatility", orDefault(config.Volatility, 15), "standard deviation of yearly returns, as a percentage")
+	runs := fs.Int("runs", 10000, "number of Monte Carlo simulations")
+	seed := fs.Int64("seed", 1, "seed for the Monte Carlo simulation, so results can be repeated")
+	horizon := fs.Int("horizon", 20, "years ahead to report the simulated assets at")
+	fs.Parse(args)
+
+	if *withdrawal <= 0 || *runs <= 0 || *horizon <= 0 {
+		return errors.New("the withdrawal rate, runs and horizon must be more than 0")
+	}
+	if *horizon > maxFIREYears {
+		return fmt.Errorf("the horizon can't be more than %d years", maxFIREYears)
+	}
+
+	if err := app.load(src); err != nil {
+		return err
+	}
+	if err := app.applyFilters(filters); err != nil {
+		return err
+	}
+	today := localDate(now(), app.location())
+
+	if *assets == 0 && app.config.Investments.Trades != "" {
+		trades, prices, err := app.loadInvestments("", "")
+		if err != nil {
+			return err
+		}
+		portfolio, err := buildPortfolio(trades, FIFO, today)
+		if err != nil {
+			return err
+		}
+		quantities := make(map[string]float64)
+		for symbol := range portfolio.Lots {
+			quantities[symbol] = portfolio.Quantity(symbol)
+		}
+		*assets = marketValue(quantities, prices, today)
+	}
+
+	span := transactionsRange(*app.transactions)
+	years := math.Max((span.End.Sub(span.Start).Hours()/24+1)/365.25, 1.0/12)
+	totalExpenses, totalIncome := app.calculateTotalExpensesAndIncome()
+	if totalIncome <= 0 {
+		return errors.New("no income found to work out a savings rate from")
+	}
+	rate := app.calculateSavingsRate(totalIncome, totalExpenses)
+
+	plan := FIREPlan{
+		Assets:   *assets,
+		Savings:  (totalIncome - totalExpenses) / years,
+		Expenses: totalExpenses / years,
+	}
+	plan.Target = plan.Expenses / (*withdrawal / 100)
+
+	fmt.Printf("Income %s a year, expenses %s a year, saving %.2f%% (%s a year)\n",
+		app.money(totalIncome/years), app.money(plan.Expenses), rate, app.money(plan.Savings))
+	fmt.Printf("Invested %s, needing %s at a %g%% withdrawal rate, in today's dollars\n\n",
+		app.money(plan.Assets), app.money(plan.Target), *withdrawal)
+
+	fmt.Printf("%-10s  %8s  %8s  %-8s\n", "Scenario", "Return", "Years", "FI date")
+	for _, scenario := range []struct {
+		name   string
+		change float64
+	}{
+		{"low", -2},
+		{"expected", 0},
+		{"high", 2},
+	} {
+		nominal := *expected + scenario.change
+		years, _ := plan.yearsToFI(func(int) float64 { return realRate(nominal, *inflation) }, 1)
+		label := "never"
+		if years >= 0 {
+			label = fmt.Sprintf("%.1f", years)
+		}
+		fmt.Printf("%-10s  %7.2f%%  %8s  %-8s\n", scenario.name, nominal, label, fiDate(today, years))
+	}
+
+	// Each simulated year's return is drawn from a normal distribution. A
+	// year can lose at most 99%, as losing everything or more would leave
+	// nothing to compound.
+	random := rand.New(rand.NewSource(*seed))
+	var reached, atHorizon []float64
+	for run := 0; run < *runs; run++ {
+		returns := make([]float64, maxFIREYears)
+		for year := range returns {
+			nominal := math.Max(*expected+random.NormFloat64()**volatility, -99)
+			returns[year] = realRate(nominal, *inflation)
+		}
+		years, value := plan.yearsToFI(func(year int) float64 { return returns[year] }, *horizon)
+		if years < 0 {
+			years = math.Inf(1)
+		}
+		reached = append(reached, years)
+		atHorizon = append(atHorizon, value)
+	}
+	sort.Float64s(reached)
+	sort.Float64s(atHorizon)
+
+	fmt.Println()
+	fmt.Printf("Monte Carlo: %d runs, seed %d, %g%% volatility\n", *runs, *seed, *volatility)
+	fmt.Printf("%-10s  %8s  %-8s  %16s\n", "Percentile", "Years", "FI date", fmt.Sprintf("After %d years", *horizon))
+	// Rows run from poor outcomes to good, so fewer years is a higher percentile
+	for _, p := range []float64{10, 25, 50, 75, 90} {
+		years := percentile(reached, 100-p)
+		label := "never"
+		if math.IsInf(years, 1) {
+			years = -1
+		} else {
+			label = fmt.Sprintf("%.1f", years)
+		}
+		fmt.Printf("%-10s  %8s  %-8s  %16.2f\n", fmt.Sprintf("%gth", p), label, fiDate(today, years), percentile(atHorizon, p))
+	}
+
+	fmt.Println()
+	for _, within := range []float64{10, 20, 30} {
+		count := sort.SearchFloat64s(reached, within+1e-9)
+		fmt.Printf("Chance of FI within %g years: %.1f%%\n", within, float64(count)/float64(len(reached))*100)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 )
 
 // -ex flag
@@ -20,6 +21,41 @@ func (app *application) handleIncludeTransactionsFlag(description string) {
 	}
 }
 
+// -acct flag
+func (app *application) handleAccountFlag(names string) {
+	if names != "" {
+		transactions := app.filterAccounts(names)
+		app.transactions = &transactions
+	}
+}
+
+// -bya flag
+func (app *application) handleByAccountFlag(report func()) {
+	all := app.transactions
+	defer func() { app.transactions = all }()
+
+	for _, name := range app.accountNames() {
+		app.transactions = all
+		transactions := app.filterAccounts(name)
+		if len(transactions) == 0 {
+			continue
+		}
+		app.transactions = &transactions
+
+		heading := name
+		if heading == "" {
+			heading = "Unassigned"
+		}
+		if account, ok := app.account(name); ok {
+			heading = fmt.Sprintf("%s (%s, %s %s)", account.Name, account.Type, account.Institution, account.Currency)
+		}
+		fmt.Println(heading)
+		fmt.Println("==================================================")
+		report()
+		fmt.Println()
+	}
+}
+
 // -ga flag
 func (app *application) handleGreaterAmountFlag(amount float64) {
 	if amount != 0 {
@@ -40,10 +76,10 @@ func (app *application) handleLesserAmountFlag(amount float64) {
 func (app *application) handleTotalExpensesFlag() {
 	totalExpenses, totalIncome := app.calculateTotalExpensesAndIncome()
 	savingsRate := app.calculateSavingsRate(totalIncome, totalExpenses)
-	fmt.Printf("Total Expenses: $%.2f\n", totalExpenses)
-	fmt.Printf("Total Income: $%.2f\n", totalIncome)
+	fmt.Printf("Total Expenses: %s\n", app.money(totalExpenses))
+	fmt.Printf("Total Income: %s\n", app.money(totalIncome))
 	fmt.Println()
-	fmt.Printf("Total: $%.2f\n", totalIncome-totalExpenses)
+	fmt.Printf("Total: %s\n", app.money(totalIncome-totalExpenses))
 	fmt.Printf("Savings Rate: %.2f%%\n", savingsRate)
 }
 
@@ -62,27 +98,50 @@ func (app *application) handleTopTrendsXFlag(numberOfTrends int, filterByDate bo
 }
 
 // -gd flag
-func (app *application) handleGreaterDateFlag(date string) {
+func (app *application) handleGreaterDateFlag(date string) error {
 	if date != "" {
-		transactions := app.filterDate(app.parseDate(date), true)
+		startDate, err := app.parseDate(date)
+		if err != nil {
+			return err
+		}
+		transactions := app.filterByDateRange(DateRange{Start: startDate})
 		app.transactions = &transactions
 	}
+	return nil
 }
 
 // -ld flag
-func (app *application) handleLesserDateFlag(date string) {
+func (app *application) handleLesserDateFlag(date string) error {
 	if date != "" {
-		transactions := app.filterDate(app.parseDate(date), false)
+		endDate, err := app.parseEndDate(date)
+		if err != nil {
+			return err
+		}
+		transactions := app.filterByDateRange(DateRange{End: endDate})
 		app.transactions = &transactions
 	}
+	return nil
 }
 
-// -md flag
-func (app *application) handleMiddleDateFlag(dates []string) {
-	if len(dates) == 2 {
-		startDate := app.parseDate(dates[0])
-		endDate := app.parseDate(dates[1])
-		transactions := app.filterByDateRange(startDate, endDate)
-		app.transactions = &transactions
+// -md flag. A single expression such as last-month covers every day it names.
+func (app *application) handleMiddleDateFlag(dates []string) error {
+	var startDate, endDate time.Time
+	var err error
+	switch len(dates) {
+	case 1:
+		startDate, endDate, err = app.parseDateRange(dates[0])
+	case 2:
+		if startDate, err = app.parseDate(dates[0]); err == nil {
+			endDate, err = app.parseEndDate(dates[1])
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
 	}
+
+	transactions := app.filterByDateRange(inclusiveRange(startDate, endDate))
+	app.transactions = &transactions
+	return nil
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// daysPerMonth is the average length of a month, used to turn day counts into
+// months.
+const daysPerMonth = 365.25 / 12
+
+// Goal is a savings target, tracked by the balance of an account or the money
+// put into a category.
+type Goal struct {
+	Target   float64 `yaml:"target"`
+	Deadline string  `yaml:"deadline"`
+	// Account tracks the goal by the account's balance, and Category by the
+	// total paid into the category, such as transfers to a holiday fund.
+	Account  string `yaml:"account"`
+	Category string `yaml:"category"`
+}
+
+// GoalProgress is how far a goal has come and when it is expected to finish.
+type GoalProgress struct {
+	Name     string
+	Goal     Goal
+	Saved    float64
+	Deadline time.Time
+	// Monthly is what needs saving each month to meet the deadline.
+	Monthly float64
+	// Projected is when the goal is expected to be met at the historical
+	// savings rate, zero if it never will be.
+	Projected time.Time
+}
+
+// Remaining returns how much is left to save.
+func (p GoalProgress) Remaining() float64 {
+	return math.Max(p.Goal.Target-p.Saved, 0)
+}
+
+// AtRisk reports whether the goal isn't expected to be met by its deadline.
+func (p GoalProgress) AtRisk() bool {
+	if p.Remaining() == 0 {
+		return false
+	}
+	return p.Projected.IsZero() || calendarDay(p.Projected) > calendarDay(p.Deadline)
+}
+
+// monthlySavings returns the average saved each month: the savings rate
+// applied to the average monthly income over the transactions' span.
+func (app *application) monthlySavings(transactions Transactions) (float64, float64) {
+	span := transactionsRange(transactions)
+	if span.Start.IsZero() {
+		return 0, 0
+	}
+
+	totalExpenses, totalIncome := app.totalsFor(transactions)
+	if totalIncome <= 0 {
+		return 0, 0
+	}
+
+	rate := app.calculateSavingsRate(totalIncome, totalExpenses)
+	months := math.Max((span.End.Sub(span.Start).Hours()/24+1)/daysPerMonth, 1)
+	return totalIncome / months * rate / 100, rate
+}
+
+// goalSaved returns how much has been saved towards a goal so far.
+func (app *application) goalSaved(goal Goal, transactions Transactions, balances map[string]RunningBalance) float64 {
+	if goal.Account != "" {
+		balance := balances[goal.Account]
+		if len(balance.Checks) == 0 {
+			return balance.Opening
+		}
+		return balance.Checks[len(balance.Checks)-1].Computed
+	}
+
+	saved := 0.0
+	for _, transaction := range filterCategories(transactions, goal.Category) {
+		saved -= transaction.Amount
+	}
+	return saved
+}
+
+// calculateGoals works out the progress of every goal. Goals are funded one
+// at a time in deadline order, so the savings go to the most urgent goal
+// first and later goals are projected from when the earlier ones finish.
+func (app *application) calculateGoals(goals map[string]Goal, transactions Transactions, monthly float64, today time.Time) ([]GoalProgress, error) {
+	balances := app.accountBalances()
+	var progress []GoalProgress
+	for name, goal := range goals {
+		if goal.Target <= 0 {
+			return nil, fmt.Errorf("goal %q: target must be more than 0", name)
+		}
+		if (goal.Account == "") == (goal.Category == "") {
+			return nil, fmt.Errorf("goal %q: link it to either an account or a category", name)
+		}
+		if _, ok := balances[goal.Account]; goal.Account != "" && !ok {
+			return nil, fmt.Errorf("goal %q: no account named %q", name, goal.Account)
+		}
+		deadline, err := app.parseEndDate(goal.Deadline)
+		if err != nil {
+			return nil, fmt.Errorf("goal %q: %w", name, err)
+		}
+
+		progress = append(progress, GoalProgress{
+			Name:     name,
+			Goal:     goal,
+			Saved:    app.goalSaved(goal, transactions, balances),
+			Deadline: deadline,
+		})
+	}
+
+	sort.Slice(progress, func(i, j int) bool {
+		if !progress[i].Deadline.Equal(progress[j].Deadline) {
+			return progress[i].Deadline.Before(progress[j].Deadline)
+		}
+		return progress[i].Name < progress[j].Name
+	})
+
+	// Months of savings already promised to earlier goals
+	committed := 0.0
+	for i := range progress {
+		p := &progress[i]
+		months := p.Deadline.Sub(today).Hours() / 24 / daysPerMonth
+		if months < 1 {
+			months = 1
+		}
+		p.Monthly = p.Remaining() / months
+
+		if p.Remaining() == 0 {
+			p.Projected = today
+			continue
+		}
+		if monthly <= 0 {
+			continue
+		}
+		committed += p.Remaining() / monthly
+		p.Projected = today.AddDate(0, 0, int(math.Ceil(committed*daysPerMonth)))
+	}
+
+	return progress, nil
+}
+
+// printGoals prints each goal's progress and flags those at risk.
+func (app *application) printGoals(progress []GoalProgress, monthly, rate float64) {
+	fmt.Printf("Saving %s a month (%.2f%% of income)\n\n", app.money(monthly), rate)
+
+	fmt.Printf("%-20s  %12s  %12s  %6s  %-10s  %12s  %-10s  %s\n", "Goal", "Target", "Saved", "Done", "Deadline", "Monthly", "Projected", "Status")
+	for _, p := range progress {
+		projected := "never"
+		if !p.Projected.IsZero() {
+			projected = p.Projected.Format("02-01-2006")
+		}
+		status := "on track"
+		switch {
+		case p.Remaining() == 0:
+			status = "reached"
+		case p.AtRisk():
+			status = "AT RISK"
+		}
+
+		fmt.Printf("%-20s  %12.2f  %12.2f  %5.1f%%  %-10s  %12.2f  %-10s  %s\n",
+			fitLabel(p.Name, 20), p.Goal.Target, p.Saved, math.Min(p.Saved/p.Goal.Target*100, 100),
+			p.Deadline.Format("02-01-2006"), p.Monthly, projected, status)
+	}
+}
+
+// goalsCommand reports progress towards the savings goals in the config. The
+// filters choose the history the savings rate is taken from.
+func (app *application) goalsCommand(args []string) error {
+	fs, src := newFlagSet("goals")
+	filters := addFilterFlags(fs)
+	fs.Parse(args)
+
+	if len(app.config.Goals) == 0 {
+		return errors.New("no goals found: add them under goals in the config")
+	}
+
+	if err := app.load(src); err != nil {
+		return err
+	}
+	all := *app.transactions
+	if err := app.applyFilters(filters); err != nil {
+		return err
+	}
+
+	monthly, rate := app.monthlySavings(*app.transactions)
+	today := localDate(now(), app.location())
+	progress, err := app.calculateGoals(app.config.Goals, all, monthly, today)
+	if err != nil {
+		return err
+	}
+
+	app.printGoals(progress, monthly, rate)
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Group totals the transactions sharing a period, category, payee or account.
+type Group struct {
+	Name     string
+	Count    int
+	Expenses float64
+	Income   float64
+	// First and Last are the dates of the group's earliest and latest
+	// transactions.
+	First time.Time
+	Last  time.Time
+	// start orders period groups chronologically.
+	start time.Time
+}
+
+// groupBy lists what transactions can be grouped by.
+var groupBy = []string{"month", "quarter", "year", "category", "payee", "account", "tag"}
+
+// groupTransactions totals transactions by period, category, payee, account
+// or tag. A transaction with several tags counts towards each, and untagged
+// transactions are left out. Periods are ordered oldest first and everything
+// else by the biggest spend.
+func groupTransactions(transactions Transactions, by string) ([]Group, error) {
+	groups := make(map[string]*Group)
+	for _, transaction := range transactions {
+		var names []string
+		var start time.Time
+		switch by {
+		case "month", "quarter", "year":
+			period, _ := parsePeriod(by)
+			start = period.start(transaction.Date)
+			names = []string{period.label(start)}
+		case "category":
+			names = []string{transaction.Category}
+		case "payee":
+			names = []string{transaction.Description}
+		case "account":
+			names = []string{transaction.Account}
+		case "tag":
+			names = transaction.Tags
+		default:
+			return nil, fmt.Errorf("unknown grouping %q: use month, quarter, year, category, payee, account or tag", by)
+		}
+
+		for _, name := range names {
+			group, ok := groups[name]
+			if !ok {
+				group = &Group{Name: name, First: transaction.Date, Last: transaction.Date, start: start}
+				groups[name] = group
+			}
+
+			group.Count++
+			if transaction.Type == Income {
+				group.Income += transaction.Amount
+			} else {
+				group.Expenses -= transaction.Amount
+			}
+			if transaction.Date.Before(group.First) {
+				group.First = transaction.Date
+			}
+			if transaction.Date.After(group.Last) {
+				group.Last = transaction.Date
+			}
+		}
+	}
+
+	var grouped []Group
+	for _, group := range groups {
+		grouped = append(grouped, *group)
+	}
+
+	sort.Slice(grouped, func(i, j int) bool {
+		if !grouped[i].start.Equal(grouped[j].start) {
+			return grouped[i].start.Before(grouped[j].start)
+		}
+		if grouped[i].Expenses != grouped[j].Expenses {
+			return grouped[i].Expenses > grouped[j].Expenses
+		}
+		return grouped[i].Name < grouped[j].Name
+	})
+
+	return grouped, nil
+}
+
+// printGroups prints one row per group with its totals.
+func (app *application) printGroups(groups []Group) {
+	nameWidth := len("Name")
+	for _, group := range groups {
+		if len(group.Name) > nameWidth {
+			nameWidth = len(group.Name)
+		}
+	}
+
+	fmt.Printf("%-*s  %6s  %12s  %12s  %12s\n", nameWidth, "Name", "Count", "Expenses", "Income", "Total")
+	var total Group
+	for _, group := range groups {
+		fmt.Printf("%-*s  %6d  %12.2f  %12.2f  %12.2f\n", nameWidth, group.Name, group.Count, group.Expenses, group.Income, group.Income-group.Expenses)
+		total.Count += group.Count
+		total.Expenses += group.Expenses
+		total.Income += group.Income
+	}
+	fmt.Printf("%-*s  %6d  %12.2f  %12.2f  %12.2f\n", nameWidth, "Total", total.Count, total.Expenses, total.Income, total.Income-total.Expenses)
+}
@@ -2,52 +2,29 @@ package main
 
 import (
 	"math"
-	"sort"
-	"time"
 )
 
-// getDateRanges returns a slice of date ranges to be used for filtering transactions.
-func (app *application) getDateRanges(filterByDate bool) [][]time.Time {
-	var dateRanges [][]time.Time
-
+// getDateRanges returns the date ranges trends are reported for: the whole
+// span of the transactions, or 4 week periods across it when filterByDate is
+// set.
+func (app *application) getDateRanges(filterByDate bool) []DateRange {
+	span := transactionsRange(*app.transactions)
 	if filterByDate {
-		startEndDates, keys := app.generateStartEndDates()
-		for _, key := range keys {
-			startDate := key
-			endDate := startEndDates[startDate]
-			dateRanges = append(dateRanges, []time.Time{startDate, endDate})
-		}
-	} else {
-		startDate := (*app.transactions)[len(*app.transactions)-1].Date
-		endDate := (*app.transactions)[0].Date
-		dateRanges = append(dateRanges, []time.Time{startDate, endDate})
+		return fourWeekRanges(span)
 	}
 
-	return dateRanges
+	return []DateRange{span}
 }
 
-// generateStartEndDates returns a map of start and end dates for each 4 week period.
-func (app *application) generateStartEndDates() (map[time.Time]time.Time, []time.Time) {
-	earliestDate := (*app.transactions)[0].Date
-	latestDate := (*app.transactions)[len(*app.transactions)-1].Date
-
-	weeks := math.Abs(latestDate.Sub(earliestDate).Hours() / 24 / 7)
-	startEndDates := make(map[time.Time]time.Time)
-	for i := 0; i < int(weeks); i += 4 {
-		startDate := earliestDate.AddDate(0, 0, -(i+4)*7)
-		endDate := earliestDate.AddDate(0, 0, -i*7)
-		startEndDates[startDate] = endDate
-	}
-
-	var keys []time.Time
-	for k := range startEndDates {
-		keys = append(keys, k)
+// fourWeekRanges splits a range into 4 week periods, oldest first. The
+// periods end on the range's last day, so the first may start before it.
+func fourWeekRanges(span DateRange) []DateRange {
+	var dateRanges []DateRange
+	for end := span.Last(); !span.Start.IsZero() && calendarDay(end) >= calendarDay(span.Start); end = end.AddDate(0, 0, -28) {
+		dateRanges = append([]DateRange{inclusiveRange(end.AddDate(0, 0, -27), end)}, dateRanges...)
 	}
-	sort.Slice(keys, func(i, j int) bool {
-		return keys[i].Before(keys[j])
-	})
 
-	return startEndDates, keys
+	return dateRanges
 }
 
 // calculateSavingsRate returns the savings rate for a given date range.
@@ -65,13 +42,6 @@ func (app *application) calculateSavingsRate(totalIncomes, totalExpenses float64
 }
 
 // filterByDateRange returns a slice of transactions that fall within the given date range.
-func (app *application) filterByDateRange(startDate, endDate time.Time) Transactions {
-	var filtered Transactions
-	for _, transaction := range *app.transactions {
-		if transaction.Date.After(startDate) && transaction.Date.Before(endDate) {
-			filtered = append(filtered, transaction)
-		}
-	}
-
-	return filtered
+func (app *application) filterByDateRange(dateRange DateRange) Transactions {
+	return dateRange.Filter(*app.transactions)
 }
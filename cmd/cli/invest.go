@@ -0,0 +1,614 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TradeType string
+
+const (
+	Buy      TradeType = "buy"
+	Sell     TradeType = "sell"
+	Dividend TradeType = "dividend"
+	Fee      TradeType = "fee"
+)
+
+// Cost basis methods
+const (
+	FIFO    = "fifo"
+	Average = "average"
+)
+
+// InvestmentConfig says where brokerage trades and prices are read from.
+type InvestmentConfig struct {
+	Trades string `yaml:"trades"`
+	Prices string `yaml:"prices"`
+	// Method is fifo, the default, or average.
+	Method string `yaml:"method"`
+}
+
+// Trade is one row of a brokerage export. Buys and sells have a quantity and
+// price, while dividends and fees have an amount.
+type Trade struct {
+	Date     time.Time
+	Type     TradeType
+	Symbol   string
+	Quantity float64
+	Price    float64
+	Fees     float64
+	Amount   float64
+}
+
+// Value returns the money a trade moves, from the investor's side: negative
+// for buys and fees, positive for sales and dividends.
+func (t Trade) Value() float64 {
+	switch t.Type {
+	case Buy:
+		return -(t.Quantity*t.Price + t.Fees)
+	case Sell:
+		return t.Quantity*t.Price - t.Fees
+	case Dividend:
+		return t.Amount - t.Fees
+	}
+	return -(t.Amount + t.Fees)
+}
+
+// loadTrades reads trades from a CSV file with the header
+// date,type,symbol,quantity,price,fees,amount. Quantity and price can be left
+// empty for dividends and fees, and amount for buys and sells.
+func loadTrades(filename string, location *time.Location, monthFirst bool) ([]Trade, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	number := func(record []string, i int) (float64, error) {
+		if i >= len(record) || strings.TrimSpace(record[i]) == "" {
+			return 0, nil
+		}
+		return strconv.ParseFloat(strings.TrimSpace(record[i]), 64)
+	}
+
+	var trades []Trade
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "date") {
+			continue
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("%s line %d: expected date, type, symbol, quantity, price, fees and amount", filename, i+1)
+		}
+
+		date, ok := parseAnyDate(strings.TrimSpace(record[0]), dateLayouts(monthFirst), location)
+		if !ok {
+			return nil, fmt.Errorf("%s line %d: unable to parse date %q", filename, i+1, record[0])
+		}
+		trade := Trade{
+			Date:   date,
+			Type:   TradeType(strings.ToLower(strings.TrimSpace(record[1]))),
+			Symbol: strings.ToUpper(strings.TrimSpace(record[2])),
+		}
+
+		for column, field := range map[int]*float64{3: &trade.Quantity, 4: &trade.Price, 5: &trade.Fees, 6: &trade.Amount} {
+			value, err := number(record, column)
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: invalid number %q", filename, i+1, record[column])
+			}
+			*field = math.Abs(value)
+		}
+
+		switch trade.Type {
+		case Buy, Sell:
+			if trade.Quantity == 0 {
+				return nil, fmt.Errorf("%s line %d: a %s needs a quantity", filename, i+1, trade.Type)
+			}
+		case Dividend, Fee:
+			if trade.Amount == 0 {
+				return nil, fmt.Errorf("%s line %d: a %s needs an amount", filename, i+1, trade.Type)
+			}
+		default:
+			return nil, fmt.Errorf("%s line %d: unknown trade type %q: use buy, sell, dividend or fee", filename, i+1, record[1])
+		}
+
+		trades = append(trades, trade)
+	}
+
+	sort.SliceStable(trades, func(i, j int) bool {
+		return calendarDay(trades[i].Date) < calendarDay(trades[j].Date)
+	})
+	return trades, nil
+}
+
+// PricePoint is a security's price on a day.
+type PricePoint struct {
+	Date  time.Time
+	Price float64
+}
+
+// Prices holds each security's prices, oldest first.
+type Prices map[string][]PricePoint
+
+// loadPrices reads prices from a CSV file with the header date,symbol,price.
+func loadPrices(filename string, location *time.Location, monthFirst bool) (Prices, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(Prices)
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "date") {
+			continue
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("%s line %d: expected date, symbol and price", filename, i+1)
+		}
+
+		date, ok := parseAnyDate(strings.TrimSpace(record[0]), dateLayouts(monthFirst), location)
+		if !ok {
+			return nil, fmt.Errorf("%s line %d: unable to parse date %q", filename, i+1, record[0])
+		}
+		price, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: invalid price: %w", filename, i+1, err)
+		}
+
+		symbol := strings.ToUpper(strings.TrimSpace(record[1]))
+		prices[symbol] = append(prices[symbol], PricePoint{Date: date, Price: price})
+	}
+
+	prices.sort()
+	return prices, nil
+}
+
+// sort puts every security's prices in date order.
+func (p Prices) sort() {
+	for _, points := range p {
+		sort.SliceStable(points, func(i, j int) bool {
+			return calendarDay(points[i].Date) < calendarDay(points[j].Date)
+		})
+	}
+}
+
+// addTrades fills in prices from buys and sells, for days the price file
+// doesn't cover. The price file wins on days it has a price.
+func (p Prices) addTrades(trades []Trade) {
+	for _, trade := range trades {
+		if trade.Type != Buy && trade.Type != Sell {
+			continue
+		}
+		points := []PricePoint{{Date: trade.Date, Price: trade.Price}}
+		p[trade.Symbol] = append(points, p[trade.Symbol]...)
+	}
+	p.sort()
+}
+
+// At returns a security's latest price on or before a date.
+func (p Prices) At(symbol string, date time.Time) (float64, bool) {
+	price, found := 0.0, false
+	for _, point := range p[symbol] {
+		if calendarDay(point.Date) > calendarDay(date) {
+			break
+		}
+		price, found = point.Price, true
+	}
+	return price, found
+}
+
+// Lot is a parcel of a security bought on one day. Cost includes fees.
+type Lot struct {
+	Symbol   string
+	Acquired time.Time
+	Quantity float64
+	Cost     float64
+}
+
+// Disposal is the sale of all or part of one lot.
+type Disposal struct {
+	Symbol   string
+	Acquired time.Time
+	Sold     time.Time
+	Quantity float64
+	Proceeds float64
+	Cost     float64
+}
+
+func (d Disposal) Gain() float64 {
+	return d.Proceeds - d.Cost
+}
+
+// Portfolio is the lots held after a run of trades, with what was sold and
+// earned along the way.
+type Portfolio struct {
+	Lots      map[string][]Lot
+	Disposals []Disposal
+	// Income holds the dividends and Charges the fees not tied to a trade.
+	Income  []Trade
+	Charges []Trade
+}
+
+// Quantity returns how much of a security is held.
+func (p Portfolio) Quantity(symbol string) float64 {
+	quantity := 0.0
+	for _, lot := range p.Lots[symbol] {
+		quantity += lot.Quantity
+	}
+	return quantity
+}
+
+// Cost returns the cost basis of a security's holding.
+func (p Portfolio) Cost(symbol string) float64 {
+	cost := 0.0
+	for _, lot := range p.Lots[symbol] {
+		cost += lot.Cost
+	}
+	return cost
+}
+
+// Symbols returns the securities held or traded, in name order.
+func (p Portfolio) Symbols() []string {
+	seen := make(map[string]bool)
+	for symbol := range p.Lots {
+		seen[symbol] = true
+	}
+	for _, disposal := range p.Disposals {
+		seen[disposal.Symbol] = true
+	}
+	for _, trade := range append(append([]Trade{}, p.Income...), p.Charges...) {
+		seen[trade.Symbol] = true
+	}
+
+	var symbols []string
+	for symbol := range seen {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// apply adds a trade to the portfolio. Sales use up the oldest lots first;
+// with the average method every lot is first given the holding's average
+// cost, so the gain is the same whichever lots are sold.
+func (p *Portfolio) apply(trade Trade, method string) error {
+	switch trade.Type {
+	case Buy:
+		p.Lots[trade.Symbol] = append(p.Lots[trade.Symbol], Lot{
+			Symbol:   trade.Symbol,
+			Acquired: trade.Date,
+			Quantity: trade.Quantity,
+			Cost:     trade.Quantity*trade.Price + trade.Fees,
+		})
+	case Sell:
+		held := p.Quantity(trade.Symbol)
+		if trade.Quantity > held+1e-9 {
+			return fmt.Errorf("%s %s: selling %g but only %g held", trade.Date.Format("02-01-2006"), trade.Symbol, trade.Quantity, held)
+		}
+
+		lots := p.Lots[trade.Symbol]
+		if method == Average {
+			average := p.Cost(trade.Symbol) / held
+			for i := range lots {
+				lots[i].Cost = lots[i].Quantity * average
+			}
+		}
+
+		remaining := trade.Quantity
+		proceeds := trade.Value()
+		for remaining > 1e-9 {
+			lot := &lots[0]
+			sold := math.Min(remaining, lot.Quantity)
+			cost := lot.Cost * sold / lot.Quantity
+			p.Disposals = append(p.Disposals, Disposal{
+				Symbol:   trade.Symbol,
+				Acquired: lot.Acquired,
+				Sold:     trade.Date,
+				Quantity: sold,
+				Proceeds: proceeds * sold / trade.Quantity,
+				Cost:     cost,
+			})
+
+			lot.Quantity -= sold
+			lot.Cost -= cost
+			remaining -= sold
+			if lot.Quantity < 1e-9 {
+				lots = lots[1:]
+			}
+		}
+		p.Lots[trade.Symbol] = lots
+	case Dividend:
+		p.Income = append(p.Income, trade)
+	case Fee:
+		p.Charges = append(p.Charges, trade)
+	}
+
+	return nil
+}
+
+// buildPortfolio applies the trades made on or before a date.
+func buildPortfolio(trades []Trade, method string, date time.Time) (Portfolio, error) {
+	portfolio := Portfolio{Lots: make(map[string][]Lot)}
+	for _, trade := range trades {
+		if calendarDay(trade.Date) > calendarDay(date) {
+			break
+		}
+		if err := portfolio.apply(trade, method); err != nil {
+			return portfolio, err
+		}
+	}
+
+	return portfolio, nil
+}
+
+// marketValue returns the value of some holdings at the prices on a date.
+func marketValue(quantities map[string]float64, prices Prices, date time.Time) float64 {
+	value := 0.0
+	for symbol, quantity := range quantities {
+		price, _ := prices.At(symbol, date)
+		value += quantity * price
+	}
+	return value
+}
+
+// timeWeightedReturn returns the growth of the holdings between trades,
+// chained together, so the timing and size of money put in or taken out
+// doesn't affect it. Dividends count towards the growth of the period they
+// are paid in, and fees against it.
+func timeWeightedReturn(trades []Trade, prices Prices, end time.Time) float64 {
+	quantities := make(map[string]float64)
+	growth := 1.0
+	previous := 0.0
+	for i := 0; i < len(trades); {
+		day := trades[i].Date
+		if calendarDay(day) > calendarDay(end) {
+			break
+		}
+
+		before := marketValue(quantities, prices, day)
+		earned := 0.0
+		for ; i < len(trades) && calendarDay(trades[i].Date) == calendarDay(day); i++ {
+			trade := trades[i]
+			switch trade.Type {
+			case Buy:
+				quantities[trade.Symbol] += trade.Quantity
+				earned -= trade.Fees
+			case Sell:
+				quantities[trade.Symbol] -= trade.Quantity
+				earned -= trade.Fees
+			default:
+				earned += trade.Value()
+			}
+		}
+
+		if previous > 0 {
+			growth *= (before + earned) / previous
+		}
+		previous = marketValue(quantities, prices, day)
+	}
+
+	if previous > 0 {
+		growth *= marketValue(quantities, prices, end) / previous
+	}
+	return growth - 1
+}
+
+// cashFlow is money moved into (negative) or out of (positive) investments.
+type cashFlow struct {
+	date   time.Time
+	amount float64
+}
+
+// moneyWeightedReturn returns the yearly rate at which the cash flows and the
+// final value are worth nothing today, the internal rate of return. It is NaN
+// when there is no such rate.
+func moneyWeightedReturn(flows []cashFlow) float64 {
+	if len(flows) < 2 {
+		return math.NaN()
+	}
+	first := flows[0].date
+	npv := func(rate float64) float64 {
+		total := 0.0
+		for _, flow := range flows {
+			years := flow.date.Sub(first).Hours() / 24 / 365
+			total += flow.amount / math.Pow(1+rate, years)
+		}
+		return total
+	}
+
+	// Bisect between a near total loss and a rate high enough to change sign
+	low, high := -0.9999, 1.0
+	for npv(high) > 0 && high < 1e6 {
+		high *= 2
+	}
+	if (npv(low) > 0) == (npv(high) > 0) {
+		return math.NaN()
+	}
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		if (npv(mid) > 0) == (npv(low) > 0) {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
+
+// printPortfolio prints the holdings with their unrealized gains, the
+// realized gains, dividends and fees of each security, and the portfolio's
+// returns.
+func (app *application) printPortfolio(portfolio Portfolio, trades []Trade, prices Prices, method string, date time.Time) {
+	fmt.Printf("Holdings at %s (%s cost)\n", date.Format("02-01-2006"), method)
+	fmt.Printf("%-8s  %12s  %12s  %10s  %10s  %12s  %12s  %8s\n", "Symbol", "Quantity", "Cost", "Avg cost", "Price", "Value", "Unrealized", "%")
+	var totalCost, totalValue float64
+	quantities := make(map[string]float64)
+	for _, symbol := range portfolio.Symbols() {
+		quantity := portfolio.Quantity(symbol)
+		if quantity < 1e-9 {
+			continue
+		}
+		quantities[symbol] = quantity
+		cost := portfolio.Cost(symbol)
+		price, ok := prices.At(symbol, date)
+		value := quantity * price
+		totalCost += cost
+		totalValue += value
+
+		priceLabel := fmt.Sprintf("%10.2f", price)
+		if !ok {
+			priceLabel = fmt.Sprintf("%10s", "no price")
+		}
+		fmt.Printf("%-8s  %12.4f  %12.2f  %10.4f  %s  %12.2f  %12.2f  %7.2f%%\n",
+			symbol, quantity, cost, cost/quantity, priceLabel, value, value-cost, (value-cost)/cost*100)
+	}
+	if totalCost > 0 {
+		fmt.Printf("%-8s  %12s  %12.2f  %10s  %10s  %12.2f  %12.2f  %7.2f%%\n",
+			"Total", "", totalCost, "", "", totalValue, totalValue-totalCost, (totalValue-totalCost)/totalCost*100)
+	}
+
+	realized := make(map[string]float64)
+	dividends := make(map[string]float64)
+	fees := make(map[string]float64)
+	for _, disposal := range portfolio.Disposals {
+		realized[disposal.Symbol] += disposal.Gain()
+	}
+	for _, trade := range portfolio.Income {
+		dividends[trade.Symbol] += trade.Value()
+	}
+	for _, trade := range portfolio.Charges {
+		fees[trade.Symbol] -= trade.Value()
+	}
+
+	fmt.Println()
+	fmt.Printf("%-8s  %12s  %12s  %12s\n", "Symbol", "Realized", "Dividends", "Fees")
+	var totalRealized, totalDividends, totalFees float64
+	for _, symbol := range portfolio.Symbols() {
+		// Fees charged to the account rather than a security have no symbol
+		label := symbol
+		if label == "" {
+			label = "account"
+		}
+		fmt.Printf("%-8s  %12.2f  %12.2f  %12.2f\n", label, realized[symbol], dividends[symbol], fees[symbol])
+		totalRealized += realized[symbol]
+		totalDividends += dividends[symbol]
+		totalFees += fees[symbol]
+	}
+	fmt.Printf("%-8s  %12.2f  %12.2f  %12.2f\n", "Total", totalRealized, totalDividends, totalFees)
+
+	var flows []cashFlow
+	for _, trade := range trades {
+		if calendarDay(trade.Date) > calendarDay(date) {
+			break
+		}
+		flows = append(flows, cashFlow{date: trade.Date, amount: trade.Value()})
+	}
+	if len(flows) == 0 {
+		return
+	}
+	flows = append(flows, cashFlow{date: date, amount: marketValue(quantities, prices, date)})
+
+	fmt.Println()
+	fmt.Printf("Returns since %s\n", flows[0].date.Format("02-01-2006"))
+	twr := timeWeightedReturn(trades, prices, date)
+	fmt.Printf("  Time-weighted   %7.2f%%", twr*100)
+	if years := date.Sub(flows[0].date).Hours() / 24 / 365; years >= 1 {
+		fmt.Printf(" (%.2f%% a year)", (math.Pow(1+twr, 1/years)-1)*100)
+	}
+	fmt.Println()
+	if mwr := moneyWeightedReturn(flows); !math.IsNaN(mwr) {
+		fmt.Printf("  Money-weighted  %7.2f%% a year\n", mwr*100)
+	}
+}
+
+// loadInvestments reads the trades and prices named by the flags or the
+// config, with prices filled in from the trades.
+func (app *application) loadInvestments(tradesFile, pricesFile string) ([]Trade, Prices, error) {
+	if tradesFile == "" {
+		tradesFile = expandPath(app.config.Investments.Trades)
+	}
+	if pricesFile == "" {
+		pricesFile = expandPath(app.config.Investments.Prices)
+	}
+	if tradesFile == "" {
+		return nil, nil, errors.New("please give a brokerage export with -trades, or set investments.trades in the config")
+	}
+
+	trades, err := loadTrades(tradesFile, app.location(), app.config.MonthFirst)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to load trades: %w", err)
+	}
+
+	prices := make(Prices)
+	if pricesFile != "" {
+		if prices, err = loadPrices(pricesFile, app.location(), app.config.MonthFirst); err != nil {
+			return nil, nil, fmt.Errorf("unable to load prices: %w", err)
+		}
+	}
+	prices.addTrades(trades)
+
+	return trades, prices, nil
+}
+
+// investCommand reports investment holdings, gains and returns from a
+// brokerage export.
+func (app *application) investCommand(args []string) error {
+	fs := flag.NewFlagSet("invest", flag.ExitOnError)
+	tradesFile := fs.String("trades", "", "CSV of trades: date,type,symbol,quantity,price,fees,amount")
+	pricesFile := fs.String("prices", "", "CSV of prices: date,symbol,price")
+	method := fs.String("method", "", "cost basis method: fifo or average.\nDefaults to investments.method from the config, or fifo")
+	at := fs.String("at", "today", "date to value the holdings at")
+	timezone := fs.String("tz", "", "timezone the dates are in")
+	fs.Parse(args)
+
+	if err := app.setTimezone(*timezone); err != nil {
+		return err
+	}
+	if *method == "" {
+		*method = app.config.Investments.Method
+	}
+	*method = strings.ToLower(*method)
+	if *method == "" {
+		*method = FIFO
+	}
+	if *method != FIFO && *method != Average {
+		return fmt.Errorf("unknown cost basis method %q: use fifo or average", *method)
+	}
+
+	date, err := app.parseEndDate(*at)
+	if err != nil {
+		return err
+	}
+
+	trades, prices, err := app.loadInvestments(*tradesFile, *pricesFile)
+	if err != nil {
+		return err
+	}
+	portfolio, err := buildPortfolio(trades, *method, date)
+	if err != nil {
+		return err
+	}
+
+	app.printPortfolio(portfolio, trades, prices, *method, date)
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPortfolioSell(t *testing.T) {
+	trades := []Trade{
+		{Date: date(2023, 1, 10), Type: Buy, Symbol: "ABC", Quantity: 10, Price: 10},
+		{Date: date(2023, 6, 10), Type: Buy, Symbol: "ABC", Quantity: 10, Price: 20},
+		{Date: date(2024, 2, 10), Type: Sell, Symbol: "ABC", Quantity: 15, Price: 30, Fees: 15},
+	}
+
+	tests := []struct {
+		method        string
+		wantDisposals int
+		wantGain      float64
+		wantCost      float64
+	}{
+		// The first lot sells whole at 100 and half the second at 100
+		{FIFO, 2, 435 - 200, 100},
+		// Every share costs the average of 15
+		{Average, 2, 435 - 225, 75},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			portfolio, err := buildPortfolio(trades, tt.method, date(2024, 6, 30))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gain := 0.0
+			for _, disposal := range portfolio.Disposals {
+				gain += disposal.Gain()
+			}
+			if len(portfolio.Disposals) != tt.wantDisposals {
+				t.Errorf("%d disposals, want %d", len(portfolio.Disposals), tt.wantDisposals)
+			}
+			if math.Abs(gain-tt.wantGain) > 1e-9 {
+				t.Errorf("gain = %.2f, want %.2f", gain, tt.wantGain)
+			}
+			if got := portfolio.Quantity("ABC"); math.Abs(got-5) > 1e-9 {
+				t.Errorf("quantity = %g, want 5", got)
+			}
+			if got := portfolio.Cost("ABC"); math.Abs(got-tt.wantCost) > 1e-9 {
+				t.Errorf("cost = %.2f, want %.2f", got, tt.wantCost)
+			}
+		})
+	}
+
+	t.Run("more than held", func(t *testing.T) {
+		oversold := append(append([]Trade{}, trades...), Trade{Date: date(2024, 3, 1), Type: Sell, Symbol: "ABC", Quantity: 6, Price: 30})
+		if _, err := buildPortfolio(oversold, FIFO, date(2024, 6, 30)); err == nil {
+			t.Error("selling more than is held should fail")
+		}
+	})
+}
+
+func TestTimeWeightedReturn(t *testing.T) {
+	prices := Prices{"ABC": {
+		{Date: date(2024, 1, 1), Price: 10},
+		{Date: date(2024, 2, 1), Price: 12},
+		{Date: date(2024, 3, 1), Price: 15},
+	}}
+	buys := []Trade{
+		{Date: date(2024, 1, 1), Type: Buy, Symbol: "ABC", Quantity: 10, Price: 10},
+		{Date: date(2024, 2, 1), Type: Buy, Symbol: "ABC", Quantity: 10, Price: 12},
+	}
+
+	tests := []struct {
+		name   string
+		trades []Trade
+		want   float64
+	}{
+		// Growth of 1.2 then 1.25, however much was bought in between
+		{"buys", buys, 0.5},
+		{"dividend", append(append([]Trade{}, buys...), Trade{Date: date(2024, 3, 1), Type: Dividend, Symbol: "ABC", Amount: 10}), 1.2*310/240 - 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timeWeightedReturn(tt.trades, prices, date(2024, 3, 1)); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("timeWeightedReturn = %.6f, want %.6f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyWeightedReturn(t *testing.T) {
+	start := date(2023, 1, 1)
+	tests := []struct {
+		name  string
+		flows []cashFlow
+		want  float64
+	}{
+		{"one year", []cashFlow{{start, -1000}, {start.AddDate(0, 0, 365), 1100}}, 0.10},
+		// 500/1.1 + 660/1.1^2 = 1000
+		{"two years", []cashFlow{{start, -1000}, {start.AddDate(0, 0, 365), 500}, {start.AddDate(0, 0, 730), 660}}, 0.10},
+		{"loss", []cashFlow{{start, -1000}, {start.AddDate(0, 0, 365), 800}}, -0.20},
+		{"no rate", []cashFlow{{start, -1000}, {start.AddDate(0, 0, 365), -100}}, math.NaN()},
+		{"one flow", []cashFlow{{start, -1000}}, math.NaN()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := moneyWeightedReturn(tt.flows)
+			if math.IsNaN(tt.want) {
+				if !math.IsNaN(got) {
+					t.Errorf("moneyWeightedReturn = %.6f, want NaN", got)
+				}
+				return
+			}
+			if math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("moneyWeightedReturn = %.6f, want %.6f", got, tt.want)
+			}
+		})
+	}
+}
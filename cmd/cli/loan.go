@@ -0,0 +1,439 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// repaymentFrequencies maps each repayment frequency to repayments a year.
+var repaymentFrequencies = map[string]int{
+	"weekly":      52,
+	"fortnightly": 26,
+	"monthly":     12,
+}
+
+// LoanPlan is a loan or mortgage, repaid in equal instalments over its term.
+// Rates change on the dates given in Rates, and the repayment is worked out
+// again over the rest of the term whenever they do.
+type LoanPlan struct {
+	Principal float64 `yaml:"principal"`
+	// Rate is the yearly interest rate as a percentage at the start.
+	Rate  float64      `yaml:"rate"`
+	Rates []RateChange `yaml:"rates"`
+	Start string       `yaml:"start"`
+	// Term is the length of the loan in years.
+	Term int `yaml:"term"`
+	// Frequency is weekly, fortnightly or monthly, the default.
+	Frequency string `yaml:"frequency"`
+	// Offset is money held against the loan, so interest is only charged on
+	// the rest. OffsetAccount takes it from an account's balance instead.
+	Offset        float64 `yaml:"offset"`
+	OffsetAccount string  `yaml:"offset_account"`
+	// Repayments are "|" separated terms matching the descriptions of
+	// repayments, as with -in, optionally only in Account.
+	Repayments string `yaml:"repayments"`
+	Account    string `yaml:"account"`
+}
+
+// RateChange is a new interest rate from a date.
+type RateChange struct {
+	From string  `yaml:"from"`
+	Rate float64 `yaml:"rate"`
+}
+
+// rateChange is a RateChange with its date parsed.
+type rateChange struct {
+	from time.Time
+	rate float64
+}
+
+// LoanPeriod is one repayment in an amortization schedule.
+type LoanPeriod struct {
+	Date      time.Time
+	Rate      float64
+	Payment   float64
+	Interest  float64
+	Principal float64
+	Offset    float64
+	Balance   float64
+	// Actual is set when the payment comes from imported repayments rather
+	// than the schedule.
+	Actual bool
+}
+
+// amortization is a loan ready to be run, with its dates parsed and its
+// offset balance looked up.
+type amortization struct {
+	loan      LoanPlan
+	frequency string
+	start     time.Time
+	rates     []rateChange
+	perYear   int
+	periods   int
+	offsetAt  func(time.Time) float64
+}
+
+// periodEnd returns the date the nth repayment is due, counting from 1.
+func (a amortization) periodEnd(n int) time.Time {
+	switch a.perYear {
+	case 52:
+		return a.start.AddDate(0, 0, 7*n)
+	case 26:
+		return a.start.AddDate(0, 0, 14*n)
+	}
+	return a.start.AddDate(0, n, 0)
+}
+
+// rateAt returns the yearly rate in force on a date.
+func (a amortization) rateAt(date time.Time) float64 {
+	rate := a.loan.Rate
+	for _, change := range a.rates {
+		if calendarDay(change.from) > calendarDay(date) {
+			break
+		}
+		rate = change.rate
+	}
+	return rate
+}
+
+// annuity returns the equal repayment that pays off a balance over a number
+// of periods at a rate per period.
+func annuity(balance, rate float64, periods int) float64 {
+	if periods <= 0 {
+		return balance
+	}
+	if rate == 0 {
+		return balance / float64(periods)
+	}
+	return balance * rate / (1 - math.Pow(1+rate, -float64(periods)))
+}
+
+// run amortizes the loan until it is paid off. paid returns what is repaid
+// in the nth period, from start up to end, given the scheduled repayment, and
+// whether that is an actual repayment.
+func (a amortization) run(paid func(n int, start, end time.Time, scheduled float64) (float64, bool)) []LoanPeriod {
+	var schedule []LoanPeriod
+	balance := a.loan.Principal
+	rate := math.NaN()
+	scheduled := 0.0
+	// Loans repaid too slowly to finish are cut off at three times the term
+	for n := 1; balance > 0.005 && n <= a.periods*3; n++ {
+		start, end := a.periodEnd(n-1), a.periodEnd(n)
+		if current := a.rateAt(start); current != rate {
+			rate = current
+			scheduled = annuity(balance, rate/100/float64(a.perYear), a.periods-n+1)
+		}
+
+		offset := math.Min(math.Max(a.offsetAt(end), 0), balance)
+		interest := (balance - offset) * rate / 100 / float64(a.perYear)
+		payment, actual := paid(n, start, end, scheduled)
+		if payment > balance+interest {
+			payment = balance + interest
+		}
+		principal := payment - interest
+		balance -= principal
+
+		schedule = append(schedule, LoanPeriod{
+			Date:      end,
+			Rate:      rate,
+			Payment:   payment,
+			Interest:  interest,
+			Principal: principal,
+			Offset:    offset,
+			Balance:   balance,
+			Actual:    actual,
+		})
+	}
+
+	return schedule
+}
+
+// prepareLoan parses a loan's dates and finds its offset balance.
+func (app *application) prepareLoan(name string, loan LoanPlan, balances map[string]RunningBalance) (amortization, error) {
+	a := amortization{loan: loan}
+	if loan.Principal <= 0 || loan.Term <= 0 || loan.Rate < 0 {
+		return a, fmt.Errorf("loan %q: needs a principal, a rate and a term in years", name)
+	}
+
+	frequency := strings.ToLower(loan.Frequency)
+	if frequency == "" {
+		frequency = "monthly"
+	}
+	perYear, ok := repaymentFrequencies[frequency]
+	if !ok {
+		return a, fmt.Errorf("loan %q: unknown frequency %q: use weekly, fortnightly or monthly", name, loan.Frequency)
+	}
+	a.frequency = frequency
+	a.perYear = perYear
+	a.periods = loan.Term * perYear
+
+	start, err := app.parseDate(loan.Start)
+	if err != nil {
+		return a, fmt.Errorf("loan %q: start: %w", name, err)
+	}
+	a.start = start
+
+	for _, change := range loan.Rates {
+		from, err := app.parseDate(change.From)
+		if err != nil {
+			return a, fmt.Errorf("loan %q: rate change: %w", name, err)
+		}
+		a.rates = append(a.rates, rateChange{from: from, rate: change.Rate})
+	}
+	sort.Slice(a.rates, func(i, j int) bool {
+		return a.rates[i].from.Before(a.rates[j].from)
+	})
+
+	a.offsetAt = func(time.Time) float64 { return loan.Offset }
+	if loan.OffsetAccount != "" {
+		running, ok := balances[loan.OffsetAccount]
+		if !ok {
+			return a, fmt.Errorf("loan %q: no account named %q", name, loan.OffsetAccount)
+		}
+		a.offsetAt = running.At
+	}
+
+	return a, nil
+}
+
+// loanRepayments returns the imported transactions that repay a loan.
+func loanRepayments(loan LoanPlan, transactions Transactions) Transactions {
+	var repayments Transactions
+	if loan.Repayments == "" {
+		return repayments
+	}
+	for _, transaction := range transactions {
+		if loan.Account != "" && transaction.Account != loan.Account {
+			continue
+		}
+		if matchesAny(transaction.Description, loan.Repayments) {
+			repayments = append(repayments, transaction)
+		}
+	}
+
+	return repayments
+}
+
+// LoanReport compares a loan's schedule with what has actually been repaid,
+// projected forward from today.
+type LoanReport struct {
+	Name      string
+	Scheduled []LoanPeriod
+	// Projected is the actual repayments up to today, then scheduled ones
+	// with any extra repayment added.
+	Projected []LoanPeriod
+	Extra     float64
+	today     time.Time
+}
+
+// loanTotals returns the interest and principal repaid across the periods.
+func loanTotals(periods []LoanPeriod) (float64, float64) {
+	var interest, principal float64
+	for _, period := range periods {
+		interest += period.Interest
+		principal += period.Principal
+	}
+	return interest, principal
+}
+
+// periodsUpTo returns the periods ending on or before a date.
+func periodsUpTo(periods []LoanPeriod, date time.Time) []LoanPeriod {
+	var before []LoanPeriod
+	for _, period := range periods {
+		if calendarDay(period.Date) > calendarDay(date) {
+			break
+		}
+		before = append(before, period)
+	}
+	return before
+}
+
+// loanBalanceAt returns the balance after the last period on or before a date.
+func loanBalanceAt(principal float64, periods []LoanPeriod, date time.Time) float64 {
+	before := periodsUpTo(periods, date)
+	if len(before) == 0 {
+		return principal
+	}
+	return before[len(before)-1].Balance
+}
+
+// loanReport works out a loan's schedule and its projection from actual
+// repayments. imported is the span of the imported transactions, outside
+// which nothing is known about what was repaid.
+func (app *application) loanReport(name string, a amortization, repayments Transactions, imported DateRange, extra float64, today time.Time) LoanReport {
+	report := LoanReport{Name: name, Extra: extra, today: today}
+	report.Scheduled = a.run(func(_ int, _, _ time.Time, scheduled float64) (float64, bool) {
+		return scheduled, false
+	})
+
+	// Without imported repayments, assume the schedule has been kept to. The
+	// same goes for periods before the first imported repayment, which the
+	// bank export may simply not go back to, and periods ending after the
+	// last imported transaction.
+	firstPaid := transactionsRange(repayments).Start
+	known := func(end time.Time) bool {
+		return len(repayments) > 0 && calendarDay(end) >= calendarDay(firstPaid) &&
+			calendarDay(end) <= calendarDay(imported.End) && calendarDay(end) <= calendarDay(today)
+	}
+	report.Projected = a.run(func(_ int, start, end time.Time, scheduled float64) (float64, bool) {
+		if !known(end) {
+			if calendarDay(end) > calendarDay(today) {
+				scheduled += extra
+			}
+			return scheduled, false
+		}
+		// Repayments made on the due date count towards that period
+		paid := 0.0
+		for _, transaction := range inclusiveRange(start.AddDate(0, 0, 1), end).Filter(repayments) {
+			paid += math.Abs(transaction.Amount)
+		}
+		return paid, true
+	})
+
+	return report
+}
+
+// loanPayoff returns the date of the last repayment, or "never" if the loan isn't
+// paid off.
+func loanPayoff(periods []LoanPeriod) string {
+	if len(periods) == 0 || periods[len(periods)-1].Balance > 0.005 {
+		return "never"
+	}
+	return periods[len(periods)-1].Date.Format("02-01-2006")
+}
+
+// printLoanReport prints what has been repaid, the balance against the
+// schedule, and how extra repayments change the payoff date and interest.
+func (app *application) printLoanReport(a amortization, report LoanReport) {
+	first := report.Scheduled[0]
+	fmt.Printf("%s: %s at %.2f%% from %s over %d years, %s repayments of %s\n",
+		report.Name, app.money(a.loan.Principal), first.Rate, a.start.Format("02-01-2006"), a.loan.Term,
+		a.frequency, app.money(first.Payment))
+
+	sofar := periodsUpTo(report.Projected, report.today)
+	interest, principal := loanTotals(sofar)
+	fmt.Printf("  Repaid so far   %12.2f  (interest %.2f, principal %.2f)\n", interest+principal, interest, principal)
+
+	balance := loanBalanceAt(a.loan.Principal, report.Projected, report.today)
+	scheduledBalance := loanBalanceAt(a.loan.Principal, report.Scheduled, report.today)
+	fmt.Printf("  Balance         %12.2f  (scheduled %.2f", balance, scheduledBalance)
+	switch ahead := scheduledBalance - balance; {
+	case ahead >= 0.005:
+		fmt.Printf(", %.2f ahead available to redraw", ahead)
+	case ahead <= -0.005:
+		fmt.Printf(", %.2f behind", -ahead)
+	}
+	fmt.Println(")")
+	if len(sofar) > 0 && sofar[len(sofar)-1].Offset > 0 {
+		fmt.Printf("  Offset          %12.2f\n", sofar[len(sofar)-1].Offset)
+	}
+
+	scheduledInterest, _ := loanTotals(report.Scheduled)
+	projectedInterest, _ := loanTotals(report.Projected)
+	label := "projected"
+	if report.Extra > 0 {
+		label = fmt.Sprintf("with %.2f extra", report.Extra)
+	}
+	fmt.Printf("  Payoff          %s scheduled, %s %s", loanPayoff(report.Scheduled), loanPayoff(report.Projected), label)
+	if periods := len(report.Scheduled) - len(report.Projected); periods != 0 && loanPayoff(report.Projected) != "never" {
+		direction := "earlier"
+		if periods < 0 {
+			direction, periods = "later", -periods
+		}
+		plural := "s"
+		if periods == 1 {
+			plural = ""
+		}
+		fmt.Printf(" (%d repayment%s %s)", periods, plural, direction)
+	}
+	fmt.Println()
+	fmt.Printf("  Total interest  %.2f scheduled, %.2f %s", scheduledInterest, projectedInterest, label)
+	if saved := scheduledInterest - projectedInterest; math.Abs(saved) >= 0.005 {
+		if saved > 0 {
+			fmt.Printf(" (saving %.2f)", saved)
+		} else {
+			fmt.Printf(" (%.2f more)", -saved)
+		}
+	}
+	fmt.Println()
+}
+
+// printLoanSchedule prints every repayment, marking those taken from imported
+// transactions.
+func printLoanSchedule(periods []LoanPeriod) {
+	fmt.Printf("%-10s  %6s  %10s  %10s  %10s  %12s  %12s\n", "Date", "Rate", "Payment", "Interest", "Principal", "Offset", "Balance")
+	for _, period := range periods {
+		marker := ""
+		if period.Actual {
+			marker = "  actual"
+		}
+		fmt.Printf("%-10s  %5.2f%%  %10.2f  %10.2f  %10.2f  %12.2f  %12.2f%s\n",
+			period.Date.Format("02-01-2006"), period.Rate, period.Payment, period.Interest, period.Principal, period.Offset, period.Balance, marker)
+	}
+}
+
+// loanCommand reports on the loans in the config, or prints one's schedule.
+func (app *application) loanCommand(args []string) error {
+	fs, src := newFlagSet("loan")
+	extra := fs.Float64("extra", 0, "extra to repay with each future repayment")
+	schedule := fs.Bool("schedule", false, "print every repayment of the named loan")
+	fs.Parse(args)
+
+	if len(app.config.Loans) == 0 {
+		return errors.New("no loans found: add them under loans in the config")
+	}
+	names := fs.Args()
+	if len(names) == 0 {
+		if *schedule {
+			return errors.New("usage: loan -schedule <name>")
+		}
+		for name := range app.config.Loans {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	// Repayments and offset accounts come from the imported transactions
+	linked := false
+	for _, name := range names {
+		loan, ok := app.config.Loans[name]
+		if !ok {
+			return fmt.Errorf("no loan named %q", name)
+		}
+		linked = linked || loan.Repayments != "" || loan.OffsetAccount != ""
+	}
+	var balances map[string]RunningBalance
+	transactions := Transactions{}
+	if linked {
+		if err := app.load(src); err != nil {
+			return err
+		}
+		transactions = *app.transactions
+		balances = app.accountBalances()
+	}
+
+	today := localDate(now(), app.location())
+	for i, name := range names {
+		loan := app.config.Loans[name]
+		a, err := app.prepareLoan(name, loan, balances)
+		if err != nil {
+			return err
+		}
+		report := app.loanReport(name, a, loanRepayments(loan, transactions), transactionsRange(transactions), *extra, today)
+
+		if *schedule {
+			printLoanSchedule(report.Projected)
+			return nil
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		app.printLoanReport(a, report)
+	}
+
+	return nil
+}
@@ -12,13 +12,46 @@ type application struct {
 	errorLog     *log.Logger
 	infoLog      *log.Logger
 	transactions *Transactions
+	accounts     Accounts
+	config       *Config
+	// imported holds the transactions as imported, before any edits.
+	imported  Transactions
+	edits     Edits
+	editsFile string
+	// timezone is where transaction dates are local calendar dates, set by
+	// -tz or the config.
+	timezone *time.Location
+	// restatement is set when amounts are restated in constant dollars.
+	restatement *Restatement
 }
 
 func main() {
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
-	filenamePtr := flag.String("f", "", "filename to process")
+	config, err := loadConfig()
+	if err != nil {
+		errorLog.Fatalf("Unable to load config: %s", err)
+	}
+
+	// Run a subcommand such as "reconcile" if one was given
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			app := &application{
+				errorLog: errorLog,
+				infoLog:  infoLog,
+				config:   config,
+			}
+
+			if err := command(app, os.Args[2:]); err != nil {
+				errorLog.Fatalln(err)
+			}
+			return
+		}
+	}
+
+	src := addSourceFlags(flag.CommandLine)
+	cpiFlags := addRealFlags(flag.CommandLine)
 
 	totalExpensesPtr := flag.Bool("e", false, "calculate total expenses")
 
@@ -27,72 +60,85 @@ func main() {
 
 	excludeTransactionsPtr := flag.String("ex", "", "exclude transactions with this description")
 	includeTransactionsPtr := flag.String("in", "", "include transactions with this description")
+	accountPtr := flag.String("acct", "", "include transactions from these accounts.\nSeparate names by |")
+	byAccountPtr := flag.Bool("bya", false, "report each account separately")
 
 	greaterAmountPtr := flag.Float64("ga", 0, "include transactions greater or equal than this amount")
 	lesserAmountPtr := flag.Float64("la", 0, "include transactions less or equal than this amount")
 
 	greaterDatePtr := flag.String("gd", "", "include transactions greater or equal than this date")
 	lesserDatePtr := flag.String("ld", "", "include transactions less or equal than this date")
-	middleDatePtr := flag.String("md", "", "include transactions between this date.\nSeparate dates by comma")
+	middleDatePtr := flag.String("md", "", "include transactions between this date.\nSeparate dates by comma, or give one expression such as last-month")
 
 	flag.Parse()
 
-	if *filenamePtr == "" {
-		errorLog.Fatalln("Please provide a filename using the -f flag")
-	}
-
 	if *includeTransactionsPtr != "" && *excludeTransactionsPtr != "" {
 		errorLog.Fatalln("Please provide only one of -ex or -in flags")
 	}
 
 	var dates []string
 	if middleDatePtr != nil && *middleDatePtr != "" {
-		if !strings.Contains(*middleDatePtr, ",") {
-			errorLog.Fatalln("Please provide two dates separated by comma")
-		}
 		dates = strings.Split(*middleDatePtr, ",")
-		if len(dates) != 2 {
+		if len(dates) > 2 {
 			errorLog.Fatalln("Please provide two dates separated by comma")
 		}
 	}
 
-	transactions, err := importCSV(*filenamePtr)
-	if err != nil {
-		errorLog.Fatalf("Unable to import CSV: %s", err)
+	app := &application{
+		errorLog: errorLog,
+		infoLog:  infoLog,
+		config:   config,
 	}
 
-	app := &application{
-		errorLog:     errorLog,
-		infoLog:      infoLog,
-		transactions: &transactions,
+	if err := app.load(src); err != nil {
+		errorLog.Fatalf("Unable to import CSV: %s", err)
 	}
 
+	app.handleAccountFlag(*accountPtr)
 	app.handleExcludeTransactionsFlag(*excludeTransactionsPtr)
 	app.handleIncludeTransactionsFlag(*includeTransactionsPtr)
 	app.handleGreaterAmountFlag(*greaterAmountPtr)
 	app.handleLesserAmountFlag(*lesserAmountPtr)
-	app.handleGreaterDateFlag(*greaterDatePtr)
-	app.handleLesserDateFlag(*lesserDatePtr)
-	app.handleMiddleDateFlag(dates)
+	if err := app.handleGreaterDateFlag(*greaterDatePtr); err != nil {
+		errorLog.Fatalln(err)
+	}
+	if err := app.handleLesserDateFlag(*lesserDatePtr); err != nil {
+		errorLog.Fatalln(err)
+	}
+	if err := app.handleMiddleDateFlag(dates); err != nil {
+		errorLog.Fatalln(err)
+	}
 
 	// After filtering transactions, check if there are any left
 	if len(*app.transactions) == 0 {
 		app.errorLog.Fatalln("No transactions found")
 	}
 
-	if *totalExpensesPtr {
-		app.handleTotalExpensesFlag()
+	if err := app.restate(*cpiFlags.cpi, *cpiFlags.base); err != nil {
+		errorLog.Fatalln(err)
 	}
-	app.handleTopTrendsFlag(*topTrendsPtr, false)
-	app.handleTopTrendsXFlag(*topTrendsXPtr, true)
-}
+	app.printRestatement()
 
-// Parse Date
-func (app *application) parseDate(date string) time.Time {
-	parsedDate, err := time.Parse("02-01-2006", date)
-	if err != nil {
-		app.errorLog.Fatalf("Unable to parse date: %s", err)
+	report := func() {
+		if *totalExpensesPtr {
+			app.handleTotalExpensesFlag()
+		}
+		app.handleTopTrendsFlag(*topTrendsPtr, false)
+		app.handleTopTrendsXFlag(*topTrendsXPtr, true)
+	}
+
+	if *byAccountPtr {
+		app.handleByAccountFlag(report)
+	} else {
+		report()
 	}
+}
 
-	return parsedDate
+// location returns the timezone transaction dates are in, the system's own
+// unless one was configured.
+func (app *application) location() *time.Location {
+	if app.timezone != nil {
+		return app.timezone
+	}
+	return time.Local
 }
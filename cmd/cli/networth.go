@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snapshot is a manually recorded value of something that has no transaction
+// history, such as a house, a car or a loan held elsewhere.
+type Snapshot struct {
+	Date time.Time
+	Name string
+	// Type groups snapshots in the breakdown, e.g. "property" or "vehicle".
+	Type      string
+	Value     float64
+	Liability bool
+}
+
+type Snapshots []Snapshot
+
+// loadSnapshots reads balance snapshots from a CSV file with the header
+// date,name,type,kind,value where kind is "asset" or "liability".
+func loadSnapshots(filename string, location *time.Location) (Snapshots, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots Snapshots
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "date") {
+			continue
+		}
+		if len(record) < 5 {
+			return nil, fmt.Errorf("%s line %d: expected date, name, type, kind and value", filename, i+1)
+		}
+
+		date, ok := parseAnyDate(strings.TrimSpace(record[0]), dateLayouts(false), location)
+		if !ok {
+			return nil, fmt.Errorf("%s line %d: unable to parse date %q", filename, i+1, record[0])
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[4]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: invalid value: %w", filename, i+1, err)
+		}
+
+		kind := strings.ToLower(strings.TrimSpace(record[3]))
+		if kind != "asset" && kind != "liability" {
+			return nil, fmt.Errorf("%s line %d: kind must be asset or liability", filename, i+1)
+		}
+
+		snapshots = append(snapshots, Snapshot{
+			Date:      date,
+			Name:      strings.TrimSpace(record[1]),
+			Type:      strings.ToLower(strings.TrimSpace(record[2])),
+			Value:     value,
+			Liability: kind == "liability",
+		})
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Date.Before(snapshots[j].Date)
+	})
+
+	return snapshots, nil
+}
+
+// NetWorth is the position at the end of one period.
+type NetWorth struct {
+	Date        time.Time
+	Assets      float64
+	Liabilities float64
+	// ByType holds the total for each account or snapshot type.
+	ByType map[string]float64
+}
+
+func (n NetWorth) Total() float64 {
+	return n.Assets - n.Liabilities
+}
+
+// RunningBalance is an account's balance after each of its transactions.
+type RunningBalance struct {
+	Opening float64
+	Checks  []BalanceCheck
+}
+
+// At returns the balance after the last transaction on or before date.
+func (r RunningBalance) At(date time.Time) float64 {
+	balance := r.Opening
+	for _, check := range r.Checks {
+		if calendarDay(check.Transaction.Date) > calendarDay(date) {
+			break
+		}
+		balance = check.Computed
+	}
+
+	return balance
+}
+
+// accountBalances returns each account's running balance. The bank's balance
+// column is trusted when present; otherwise the balance runs from the
+// account's opening balance. Balances are worked out from the transactions as
+// imported, since excluding or splitting a row doesn't change the balance.
+func (app *application) accountBalances() map[string]RunningBalance {
+	balances := make(map[string]RunningBalance)
+	for _, name := range app.accountNames() {
+		var transactions Transactions
+		for _, transaction := range app.imported {
+			if transaction.Account == name {
+				transactions = append(transactions, transaction)
+			}
+		}
+		transactions = chronological(transactions)
+
+		opening := 0.0
+		if account, ok := app.account(name); ok {
+			opening = account.OpeningBalance
+		}
+		if inferred, ok := inferOpeningBalance(transactions); ok {
+			opening = inferred
+		}
+
+		checks := calculateRunningBalances(transactions, opening)
+		for i := range checks {
+			if checks[i].Transaction.HasBalance {
+				checks[i].Computed = checks[i].Transaction.Balance
+			}
+		}
+		balances[name] = RunningBalance{
+			Opening: opening,
+			Checks:  checks,
+		}
+	}
+
+	return balances
+}
+
+// calculateNetWorth returns the net worth at the end of each period covered by
+// the transactions and snapshots.
+func (app *application) calculateNetWorth(snapshots Snapshots, period Period) []NetWorth {
+	balances := app.accountBalances()
+
+	earliest := (*app.transactions)[0].Date
+	latest := earliest
+	for _, transaction := range *app.transactions {
+		if transaction.Date.Before(earliest) {
+			earliest = transaction.Date
+		}
+		if transaction.Date.After(latest) {
+			latest = transaction.Date
+		}
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Date.Before(earliest) {
+			earliest = snapshot.Date
+		}
+		if snapshot.Date.After(latest) {
+			latest = snapshot.Date
+		}
+	}
+
+	var netWorths []NetWorth
+	for _, dateRange := range periodRanges(earliest, latest, period) {
+		endDate := dateRange.Last()
+		netWorth := NetWorth{
+			Date:   endDate,
+			ByType: make(map[string]float64),
+		}
+
+		for name, runningBalance := range balances {
+			accountType := Checking
+			if account, ok := app.account(name); ok {
+				accountType = account.Type
+			}
+
+			balance := runningBalance.At(endDate)
+			netWorth.ByType[string(accountType)] += balance
+			if accountType.IsLiability() {
+				netWorth.Liabilities -= balance
+			} else {
+				netWorth.Assets += balance
+			}
+		}
+
+		// Carry each snapshot's latest value forward
+		latestValues := make(map[string]Snapshot)
+		for _, snapshot := range snapshots {
+			if calendarDay(snapshot.Date) > calendarDay(endDate) {
+				break
+			}
+			latestValues[snapshot.Name] = snapshot
+		}
+		for _, snapshot := range latestValues {
+			if snapshot.Liability {
+				netWorth.Liabilities += snapshot.Value
+				netWorth.ByType[snapshot.Type] -= snapshot.Value
+			} else {
+				netWorth.Assets += snapshot.Value
+				netWorth.ByType[snapshot.Type] += snapshot.Value
+			}
+		}
+
+		netWorths = append(netWorths, netWorth)
+	}
+
+	return netWorths
+}
+
+// printNetWorth prints the net worth at each period end with the change from
+// the previous period and a breakdown by type.
+func (app *application) printNetWorth(netWorths []NetWorth) {
+	typeSet := make(map[string]bool)
+	for _, netWorth := range netWorths {
+		for name := range netWorth.ByType {
+			typeSet[name] = true
+		}
+	}
+	var types []string
+	for name := range typeSet {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+
+	fmt.Printf("%-10s  %12s  %12s  %12s  %12s", "Date", "Net Worth", "Change", "Assets", "Liabilities")
+	for _, name := range types {
+		fmt.Printf("  %12s", name)
+	}
+	fmt.Println()
+
+	for i, netWorth := range netWorths {
+		change := ""
+		if i > 0 {
+			change = fmt.Sprintf("%+.2f", netWorth.Total()-netWorths[i-1].Total())
+		}
+		fmt.Printf("%-10s  %12.2f  %12s  %12.2f  %12.2f",
+			netWorth.Date.Format("02-01-2006"), netWorth.Total(), change, netWorth.Assets, netWorth.Liabilities)
+		for _, name := range types {
+			fmt.Printf("  %12.2f", netWorth.ByType[name])
+		}
+		fmt.Println()
+	}
+
+	if len(netWorths) > 1 {
+		first, last := netWorths[0], netWorths[len(netWorths)-1]
+		fmt.Println()
+		fmt.Printf("Change from %s to %s: $%.2f\n", first.Date.Format("02-01-2006"), last.Date.Format("02-01-2006"), last.Total()-first.Total())
+	}
+}
+
+// netWorthCommand reports net worth at the end of each period.
+func (app *application) netWorthCommand(args []string) error {
+	fs, src := newFlagSet("networth")
+	snapshotsFile := fs.String("snapshots", "", "file of manual balance snapshots for assets and liabilities")
+	periodName := fs.String("p", string(Monthly), "period to report: month, quarter or year")
+	fs.Parse(args)
+
+	period, err := parsePeriod(*periodName)
+	if err != nil {
+		return err
+	}
+
+	if err := app.load(src); err != nil {
+		return err
+	}
+
+	var snapshots Snapshots
+	if *snapshotsFile != "" {
+		snapshots, err = loadSnapshots(*snapshotsFile, app.location())
+		if err != nil {
+			return fmt.Errorf("unable to load snapshots: %w", err)
+		}
+	}
+
+	app.printNetWorth(app.calculateNetWorth(snapshots, period))
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type Period string
+
+const (
+	Monthly   Period = "month"
+	Quarterly Period = "quarter"
+	Yearly    Period = "year"
+)
+
+// parsePeriod returns the period named by a -p flag.
+func parsePeriod(name string) (Period, error) {
+	switch period := Period(name); period {
+	case Monthly, Quarterly, Yearly:
+		return period, nil
+	}
+
+	return "", fmt.Errorf("unknown period %q: use %s, %s or %s", name, Monthly, Quarterly, Yearly)
+}
+
+// start returns the first day of the period containing t.
+func (p Period) start(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	switch p {
+	case Quarterly:
+		month -= (month - 1) % 3
+	case Yearly:
+		month = time.January
+	}
+
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
+
+// next returns the first day of the period after the one starting at t.
+func (p Period) next(t time.Time) time.Time {
+	switch p {
+	case Quarterly:
+		return t.AddDate(0, 3, 0)
+	case Yearly:
+		return t.AddDate(1, 0, 0)
+	}
+
+	return t.AddDate(0, 1, 0)
+}
+
+// label returns a short name for the period starting at t.
+func (p Period) label(t time.Time) string {
+	switch p {
+	case Quarterly:
+		return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+	case Yearly:
+		return t.Format("2006")
+	}
+
+	return t.Format("Jan 2006")
+}
+
+// periodRange returns the period starting at start, which runs up to but
+// not including the start of the next.
+func (p Period) periodRange(start time.Time) DateRange {
+	return DateRange{Start: start, End: p.next(start), Exclusive: true}
+}
+
+// periodRanges returns every period between the start and end dates.
+func periodRanges(start, end time.Time, period Period) []DateRange {
+	var dateRanges []DateRange
+	for periodStart := period.start(start); calendarDay(periodStart) <= calendarDay(end); periodStart = period.next(periodStart) {
+		dateRanges = append(dateRanges, period.periodRange(periodStart))
+	}
+
+	return dateRanges
+}
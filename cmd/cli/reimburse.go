@@ -0,0 +1,334 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// agingBuckets are the ages, in days, outstanding reimbursements are grouped
+// by. Anything older falls into the last bucket.
+var agingBuckets = []struct {
+	label  string
+	maxAge int
+}{
+	{"0-30", 30},
+	{"31-60", 60},
+	{"61-90", 90},
+	{"90+", math.MaxInt},
+}
+
+// outstanding returns how much of a reimbursable expense is still owed.
+func (t Transaction) outstanding() float64 {
+	if t.Reimburse == "" || t.Type != Expense {
+		return 0
+	}
+	owed := -t.Amount - t.Reimbursed
+	if owed < 0.005 {
+		return 0
+	}
+	return owed
+}
+
+// outstandingExpenses returns the reimbursable expenses not yet fully paid
+// back, oldest first, optionally only those owed by one payer.
+func outstandingExpenses(transactions Transactions, payer string) Transactions {
+	var owed Transactions
+	for _, transaction := range chronological(transactions) {
+		if transaction.outstanding() == 0 {
+			continue
+		}
+		if payer != "" && !strings.EqualFold(transaction.Reimburse, payer) {
+			continue
+		}
+		owed = append(owed, transaction)
+	}
+
+	return owed
+}
+
+// allocateReimbursement splits a payment across the expenses in order, paying
+// each off in full before moving to the next.
+func allocateReimbursement(amount float64, expenses Transactions) []Reimbursement {
+	var reimbursements []Reimbursement
+	for _, expense := range expenses {
+		if amount < 0.005 {
+			break
+		}
+
+		paid := expense.outstanding()
+		if paid > amount {
+			paid = amount
+		}
+		reimbursements = append(reimbursements, Reimbursement{ID: expense.ID, Amount: math.Round(paid*100) / 100})
+		amount -= paid
+	}
+
+	return reimbursements
+}
+
+// ageDays returns how many whole days ago a transaction was made.
+func ageDays(transaction Transaction) int {
+	today := localDate(now(), transaction.Date.Location())
+	return int(math.Round(today.Sub(transaction.Date).Hours() / 24))
+}
+
+// printOutstanding prints every outstanding reimbursement with its age, then
+// the totals owed by each payer in each aging bucket.
+func (app *application) printOutstanding(owed Transactions) {
+	if len(owed) == 0 {
+		fmt.Println("No outstanding reimbursements")
+		return
+	}
+
+	fmt.Printf("%-14s  %-10s  %-16s  %10s  %10s  %10s  %5s  %s\n", "ID", "Date", "Payer", "Amount", "Paid", "Owed", "Days", "Description")
+	byPayer := make(map[string][]float64)
+	var payers []string
+	for _, transaction := range owed {
+		age := ageDays(transaction)
+		fmt.Printf("%-14s  %-10s  %-16s  %10.2f  %10.2f  %10.2f  %5d  %s\n",
+			transaction.ID, transaction.Date.Format("02-01-2006"), fitLabel(transaction.Reimburse, 16),
+			-transaction.Amount, transaction.Reimbursed, transaction.outstanding(), age, transaction.Description)
+
+		if _, ok := byPayer[transaction.Reimburse]; !ok {
+			byPayer[transaction.Reimburse] = make([]float64, len(agingBuckets)+1)
+			payers = append(payers, transaction.Reimburse)
+		}
+		for i, bucket := range agingBuckets {
+			if age <= bucket.maxAge {
+				byPayer[transaction.Reimburse][i] += transaction.outstanding()
+				break
+			}
+		}
+		byPayer[transaction.Reimburse][len(agingBuckets)] += transaction.outstanding()
+	}
+	sort.Strings(payers)
+
+	fmt.Println()
+	fmt.Printf("%-16s", "Payer")
+	for _, bucket := range agingBuckets {
+		fmt.Printf("  %10s", bucket.label)
+	}
+	fmt.Printf("  %10s\n", "Total")
+
+	totals := make([]float64, len(agingBuckets)+1)
+	for _, payer := range payers {
+		fmt.Printf("%-16s", fitLabel(payer, 16))
+		for i, amount := range byPayer[payer] {
+			fmt.Printf("  %10.2f", amount)
+			totals[i] += amount
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%-16s", "Total")
+	for _, amount := range totals {
+		fmt.Printf("  %10.2f", amount)
+	}
+	fmt.Println()
+}
+
+// reimburseCommand marks expenses as reimbursable, links incoming payments to
+// them and reports what is still owed.
+func (app *application) reimburseCommand(args []string) error {
+	usage := errors.New("usage: reimburse mark|unmark [filters] -payer <name> | reimburse link [filters] [-payer <name>] [-expense <id>...] | reimburse report [filters]")
+	if len(args) == 0 {
+		return usage
+	}
+	action := args[0]
+
+	fs, src := newFlagSet("reimburse " + action)
+	filters := addFilterFlags(fs)
+	payer := fs.String("payer", "", "who is expected to pay the expenses back")
+	payment := fs.String("payment", "", "ID of the incoming payment to link.\nFound from the filters when empty")
+	var expenseIDs idsFlag
+	fs.Var(&expenseIDs, "expense", "ID of an expense the payment pays back.\nRepeat for each expense, or leave out to pay the payer's oldest expenses first")
+	amount := fs.Float64("amount", 0, "amount of the payment to link.\nDefaults to all of it that isn't already linked")
+	dryRun := fs.Bool("n", false, "list the transactions that would change without saving")
+	fs.Parse(args[1:])
+
+	switch action {
+	case "mark":
+		if *payer == "" {
+			return errors.New("please name who will pay the expenses back with -payer")
+		}
+	case "unmark", "link", "report":
+	default:
+		return usage
+	}
+
+	if err := app.load(src); err != nil {
+		return err
+	}
+	all := *app.transactions
+	if err := app.applyFilters(filters); err != nil {
+		return err
+	}
+
+	switch action {
+	case "mark", "unmark":
+		return app.markReimbursable(*app.transactions, *payer, *dryRun)
+	case "link":
+		return app.linkReimbursement(all, *payment, *payer, expenseIDs, *amount, *dryRun)
+	}
+
+	// Limit the report to what the filters left, and to one payer if given
+	app.printOutstanding(outstandingExpenses(*app.transactions, *payer))
+	return nil
+}
+
+// markReimbursable sets who will pay back each expense, or clears it when
+// payer is empty, and saves the edits.
+func (app *application) markReimbursable(transactions Transactions, payer string, dryRun bool) error {
+	changed := make(map[string]bool)
+	for _, transaction := range transactions {
+		id := splitParent(transaction.ID)
+		if transaction.Type != Expense || changed[id] || transaction.Reimburse == payer {
+			continue
+		}
+
+		edit := app.edits[id]
+		edit.Reimburse = payer
+		app.edits[id] = edit
+		changed[id] = true
+		fmt.Printf("  %s  %10.2f  %s\n", transaction.Date.Format("02-01-2006"), transaction.Amount, transaction.Description)
+	}
+
+	if dryRun {
+		fmt.Printf("Would have changed %d expenses\n", len(changed))
+		return nil
+	}
+
+	if len(changed) > 0 {
+		if err := saveEdits(app.editsFile, app.edits); err != nil {
+			return fmt.Errorf("unable to save edits: %w", err)
+		}
+	}
+	if payer == "" {
+		fmt.Printf("Unmarked %d expenses\n", len(changed))
+	} else {
+		fmt.Printf("Marked %d expenses as reimbursable by %s\n", len(changed), payer)
+	}
+	return nil
+}
+
+// linkReimbursement links an incoming payment to the expenses it pays back.
+// The payment is given by ID, or is the only incoming payment the filters
+// leave.
+func (app *application) linkReimbursement(all Transactions, paymentID, payer string, expenseIDs []string, amount float64, dryRun bool) error {
+	var payment Transaction
+	if paymentID != "" {
+		found, ok := findTransaction(all, paymentID)
+		if !ok {
+			return fmt.Errorf("no transaction with ID %q", paymentID)
+		}
+		payment = found
+	} else {
+		incoming := app.filterTransactionsByType(*app.transactions, Income)
+		if len(incoming) != 1 {
+			for _, transaction := range incoming {
+				fmt.Printf("  %-14s  %s  %10.2f  %s\n", transaction.ID, transaction.Date.Format("02-01-2006"), transaction.Amount, transaction.Description)
+			}
+			return fmt.Errorf("the filters match %d incoming payments: narrow them down to one or give its ID with -payment", len(incoming))
+		}
+		payment = incoming[0]
+	}
+	if payment.Type != Income {
+		return errors.New("reimbursements are incoming payments, but this transaction is an expense")
+	}
+
+	unlinked := payment.Amount - payment.Reimbursement
+	if amount == 0 || amount > unlinked {
+		amount = unlinked
+	}
+	if amount < 0.005 {
+		return errors.New("all of this payment is already linked to expenses")
+	}
+
+	var expenses Transactions
+	if len(expenseIDs) > 0 {
+		seen := make(map[string]bool)
+		for _, id := range expenseIDs {
+			expense, ok := findTransaction(all, id)
+			if !ok {
+				return fmt.Errorf("no transaction with ID %q", id)
+			}
+			if seen[expense.ID] {
+				return fmt.Errorf("%s is given more than once with -expense", id)
+			}
+			seen[expense.ID] = true
+			if expense.outstanding() == 0 {
+				return fmt.Errorf("%s (%s) has nothing outstanding to reimburse", id, expense.Description)
+			}
+			expenses = append(expenses, expense)
+		}
+	} else {
+		if payer == "" {
+			return errors.New("please give the expenses with -expense, or the payer whose oldest expenses are paid first with -payer")
+		}
+		expenses = outstandingExpenses(all, payer)
+		if len(expenses) == 0 {
+			return fmt.Errorf("%s has nothing outstanding to reimburse", payer)
+		}
+	}
+
+	reimbursements := allocateReimbursement(amount, expenses)
+	linked := 0.0
+	for i, reimbursement := range reimbursements {
+		fmt.Printf("  %s  %10.2f  of %10.2f  %s\n", expenses[i].Date.Format("02-01-2006"), reimbursement.Amount, expenses[i].outstanding(), expenses[i].Description)
+		linked += reimbursement.Amount
+	}
+	if left := amount - linked; left >= 0.005 {
+		fmt.Printf("Warning: %.2f of the payment is left unlinked, as the expenses only had %.2f outstanding\n", left, linked)
+	}
+
+	if dryRun {
+		fmt.Printf("Would have linked %.2f of %s on %s\n", linked, payment.Description, payment.Date.Format("02-01-2006"))
+		return nil
+	}
+
+	id := splitParent(payment.ID)
+	edit := app.edits[id]
+	for _, reimbursement := range reimbursements {
+		merged := false
+		for i := range edit.Reimburses {
+			if edit.Reimburses[i].ID == reimbursement.ID {
+				edit.Reimburses[i].Amount += reimbursement.Amount
+				merged = true
+			}
+		}
+		if !merged {
+			edit.Reimburses = append(edit.Reimburses, reimbursement)
+		}
+	}
+	app.edits[id] = edit
+
+	if err := saveEdits(app.editsFile, app.edits); err != nil {
+		return fmt.Errorf("unable to save edits: %w", err)
+	}
+	fmt.Printf("Linked %.2f of %s on %s to %d expenses\n", linked, payment.Description, payment.Date.Format("02-01-2006"), len(reimbursements))
+	return nil
+}
+
+// findTransaction returns the transaction with the ID.
+func findTransaction(transactions Transactions, id string) (Transaction, bool) {
+	for _, transaction := range transactions {
+		if transaction.ID == id {
+			return transaction, true
+		}
+	}
+	return Transaction{}, false
+}
+
+// idsFlag collects repeated transaction ID flags.
+type idsFlag []string
+
+func (i *idsFlag) String() string {
+	return strings.Join(*i, ",")
+}
+
+func (i *idsFlag) Set(value string) error {
+	*i = append(*i, value)
+	return nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// SavedReport bundles the filters, grouping and output format of a report run
+// regularly, so it can be run by name.
+type SavedReport struct {
+	Description string `yaml:"description"`
+	// File, Accounts, Categories and Tags override the config's defaults.
+	File       string `yaml:"file"`
+	Accounts   string `yaml:"accounts"`
+	Categories string `yaml:"categories"`
+	Tags       string `yaml:"tags"`
+
+	// Filters, named after the flags they behave like
+	Include       string  `yaml:"include"`
+	Exclude       string  `yaml:"exclude"`
+	Account       string  `yaml:"account"`
+	Tag           string  `yaml:"tag"`
+	GreaterAmount float64 `yaml:"greater_amount"`
+	LesserAmount  float64 `yaml:"lesser_amount"`
+	GreaterDate   string  `yaml:"greater_date"`
+	LesserDate    string  `yaml:"lesser_date"`
+
+	// Summary prints total expenses, income and savings rate like -e.
+	Summary bool `yaml:"summary"`
+	// Trends prints this many top trends like -t.
+	Trends int `yaml:"trends"`
+	// Group totals by month, quarter, year, category, payee, account or tag.
+	Group string `yaml:"group"`
+	// Format is text, csv or chart.
+	Format string `yaml:"format"`
+	// CPI and Base restate amounts in constant dollars, like -cpi and -base.
+	CPI  string `yaml:"cpi"`
+	Base string `yaml:"base"`
+}
+
+// runSavedReport loads, filters and prints a saved report.
+func (app *application) runSavedReport(report SavedReport) error {
+	switch report.Format {
+	case "", "text", "csv", "chart":
+	default:
+		return fmt.Errorf("unknown format %q: use text, csv or chart", report.Format)
+	}
+
+	src := source{
+		filename:   &report.File,
+		accounts:   &report.Accounts,
+		account:    new(string),
+		categories: &report.Categories,
+		tags:       &report.Tags,
+		edits:      new(string),
+		timezone:   new(string),
+		tag:        &report.Tag,
+	}
+	if err := app.load(src); err != nil {
+		return err
+	}
+
+	app.handleAccountFlag(report.Account)
+	app.handleExcludeTransactionsFlag(report.Exclude)
+	app.handleIncludeTransactionsFlag(report.Include)
+	app.handleGreaterAmountFlag(report.GreaterAmount)
+	app.handleLesserAmountFlag(report.LesserAmount)
+	if err := app.handleGreaterDateFlag(report.GreaterDate); err != nil {
+		return err
+	}
+	if err := app.handleLesserDateFlag(report.LesserDate); err != nil {
+		return err
+	}
+
+	if len(*app.transactions) == 0 {
+		return errors.New("no transactions found")
+	}
+	if err := app.restate(report.CPI, report.Base); err != nil {
+		return err
+	}
+
+	var groups []Group
+	if report.Group != "" {
+		grouped, err := groupTransactions(*app.transactions, report.Group)
+		if err != nil {
+			return err
+		}
+		groups = grouped
+	}
+
+	if report.Format != "csv" {
+		app.printRestatement()
+	}
+
+	switch report.Format {
+	case "csv":
+		return app.writeReportCSV(groups)
+	case "chart":
+		style := detectChartStyle(false, 0)
+		var trends []Trend
+		for _, group := range groups {
+			trends = append(trends, Trend{Description: group.Name, TotalAmount: group.Expenses})
+		}
+		if groups == nil {
+			topX := report.Trends
+			if topX == 0 {
+				topX = 10
+			}
+			trends = app.calculateTopTrends(*app.transactions, topX, Expense)
+		}
+		for _, line := range style.barChart(trends) {
+			fmt.Println(line)
+		}
+	default:
+		if report.Summary {
+			app.handleTotalExpensesFlag()
+			fmt.Println()
+		}
+		app.handleTopTrendsFlag(report.Trends, false)
+		if report.Group != "" {
+			app.printGroups(groups)
+		}
+	}
+
+	return nil
+}
+
+// writeReportCSV writes the groups to standard output as CSV, or the
+// transactions themselves when the report isn't grouped.
+func (app *application) writeReportCSV(groups []Group) error {
+	writer := csv.NewWriter(os.Stdout)
+
+	if groups == nil {
+		writer.Write([]string{"date", "amount", "description", "category", "account"})
+		for _, transaction := range *app.transactions {
+			writer.Write([]string{
+				transaction.Date.Format("02-01-2006"),
+				strconv.FormatFloat(transaction.Amount, 'f', 2, 64),
+				transaction.Description,
+				transaction.Category,
+				transaction.Account,
+			})
+		}
+	} else {
+		writer.Write([]string{"name", "count", "expenses", "income", "total"})
+		for _, group := range groups {
+			writer.Write([]string{
+				group.Name,
+				strconv.Itoa(group.Count),
+				strconv.FormatFloat(group.Expenses, 'f', 2, 64),
+				strconv.FormatFloat(group.Income, 'f', 2, 64),
+				strconv.FormatFloat(group.Income-group.Expenses, 'f', 2, 64),
+			})
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// reportCommand lists the saved reports in the config file or runs one by
+// name.
+func (app *application) reportCommand(args []string) error {
+	usage := errors.New("usage: report list | report run <name>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch args[0] {
+	case "list":
+		if len(app.config.Reports) == 0 {
+			fmt.Println("No saved reports. Add them under reports: in the config file.")
+			return nil
+		}
+
+		var names []string
+		for name := range app.config.Reports {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Printf("%-20s %s\n", name, app.config.Reports[name].Description)
+		}
+	case "run":
+		if len(args) < 2 {
+			return usage
+		}
+
+		report, ok := app.config.Reports[args[1]]
+		if !ok {
+			return fmt.Errorf("no saved report named %q", args[1])
+		}
+		return app.runSavedReport(report)
+	default:
+		return usage
+	}
+
+	return nil
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Split methods for shared expenses
+const (
+	SplitEqual   = "equal"
+	SplitPercent = "percent"
+	SplitExact   = "exact"
+)
+
+// Sharing divides an expense between the person who paid it and others. With
+// the percent and exact methods each share gives a percentage or amount, and
+// the payer's own share is whatever is left.
+type Sharing struct {
+	PaidBy string  `json:"paid_by"`
+	Method string  `json:"method"`
+	Shares []Share `json:"shares"`
+}
+
+// Share is one person's part of a shared expense.
+type Share struct {
+	Person string  `json:"person"`
+	Value  float64 `json:"value,omitempty"`
+}
+
+// Settlement records a transaction as a payment between two people to settle
+// shared expenses, rather than an expense or income of its own.
+type Settlement struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// part returns the sharing for a fraction of the expense, such as one part of
+// a split transaction. Exact shares are scaled by the fraction, so they are
+// only charged once across the parts; equal and percent shares already scale
+// with the amount.
+func (s *Sharing) part(fraction float64) *Sharing {
+	if s == nil || s.Method != SplitExact {
+		return s
+	}
+	scaled := *s
+	scaled.Shares = make([]Share, len(s.Shares))
+	for i, share := range s.Shares {
+		scaled.Shares[i] = Share{Person: share.Person, Value: share.Value * fraction}
+	}
+	return &scaled
+}
+
+// owed returns how much of an amount each person is responsible for,
+// including the payer.
+func (s Sharing) owed(amount float64) (map[string]float64, error) {
+	owed := make(map[string]float64)
+	remainder := amount
+	switch s.Method {
+	case SplitEqual, "":
+		each := amount / float64(len(s.Shares)+1)
+		for _, share := range s.Shares {
+			owed[share.Person] += each
+			remainder -= each
+		}
+	case SplitPercent:
+		for _, share := range s.Shares {
+			owed[share.Person] += amount * share.Value / 100
+			remainder -= amount * share.Value / 100
+		}
+	case SplitExact:
+		for _, share := range s.Shares {
+			owed[share.Person] += share.Value
+			remainder -= share.Value
+		}
+	default:
+		return nil, fmt.Errorf("unknown split %q: use %s, %s or %s", s.Method, SplitEqual, SplitPercent, SplitExact)
+	}
+
+	if remainder < -0.005 {
+		return nil, fmt.Errorf("the shares add up to more than %.2f", amount)
+	}
+	owed[s.PaidBy] += remainder
+	return owed, nil
+}
+
+// parseShares reads the -with flag: comma separated names, each followed by
+// :value for the percent and exact methods.
+func parseShares(with, method string) ([]Share, error) {
+	var shares []Share
+	var total float64
+	for _, field := range strings.Split(with, ",") {
+		person, value, hasValue := strings.Cut(strings.TrimSpace(field), ":")
+		if person == "" {
+			continue
+		}
+
+		share := Share{Person: person}
+		switch {
+		case method == SplitEqual && hasValue:
+			return nil, fmt.Errorf("equal splits don't take a value for %s", person)
+		case method != SplitEqual && !hasValue:
+			return nil, fmt.Errorf("please give %s's share as %s:<%s>", person, person, method)
+		case hasValue:
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed < 0 {
+				return nil, fmt.Errorf("invalid share %q for %s", value, person)
+			}
+			share.Value = parsed
+			total += parsed
+		}
+		shares = append(shares, share)
+	}
+
+	if len(shares) == 0 {
+		return nil, errors.New("please list who the expenses are shared with using -with")
+	}
+	if method == SplitPercent && total > 100 {
+		return nil, fmt.Errorf("the percentages add up to %.2f%%", total)
+	}
+
+	return shares, nil
+}
+
+// me returns the name of the person whose bank transactions are loaded.
+func (app *application) me() string {
+	if app.config.Me != "" {
+		return app.config.Me
+	}
+	return "Me"
+}
+
+// LedgerEntry is a shared expense or settlement, with every person's balance
+// after it.
+type LedgerEntry struct {
+	Transaction Transaction
+	Balances    map[string]float64
+}
+
+// calculateBalances runs through the shared expenses and settlements in date
+// order. A positive balance is owed to that person, a negative one is owed by
+// them.
+func calculateBalances(transactions Transactions) ([]LedgerEntry, map[string]float64, error) {
+	balances := make(map[string]float64)
+	var ledger []LedgerEntry
+	for _, transaction := range chronological(transactions) {
+		amount := math.Abs(transaction.Amount)
+		switch {
+		case transaction.Sharing != nil:
+			owed, err := transaction.Sharing.owed(amount)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s %s: %w", transaction.Date.Format("02-01-2006"), transaction.Description, err)
+			}
+			balances[transaction.Sharing.PaidBy] += amount
+			for person, share := range owed {
+				balances[person] -= share
+			}
+		case transaction.Settlement != nil:
+			balances[transaction.Settlement.From] += amount
+			balances[transaction.Settlement.To] -= amount
+		default:
+			continue
+		}
+
+		snapshot := make(map[string]float64)
+		for person, balance := range balances {
+			snapshot[person] = balance
+		}
+		ledger = append(ledger, LedgerEntry{Transaction: transaction, Balances: snapshot})
+	}
+
+	return ledger, balances, nil
+}
+
+// Payment is one payment needed to settle up.
+type Payment struct {
+	From   string
+	To     string
+	Amount float64
+}
+
+// settleUp returns the payments that clear every balance. The largest debt
+// is paid to the largest creditor each time, which needs at most one fewer
+// payment than there are people with a balance.
+func settleUp(balances map[string]float64) []Payment {
+	type balance struct {
+		person string
+		amount float64
+	}
+	var creditors, debtors []balance
+	for person, amount := range balances {
+		amount = math.Round(amount*100) / 100
+		switch {
+		case amount > 0:
+			creditors = append(creditors, balance{person, amount})
+		case amount < 0:
+			debtors = append(debtors, balance{person, -amount})
+		}
+	}
+
+	var payments []Payment
+	for len(creditors) > 0 && len(debtors) > 0 {
+		// Biggest first, by name when equal so the result is stable
+		for _, list := range [][]balance{creditors, debtors} {
+			sort.Slice(list, func(i, j int) bool {
+				if list[i].amount != list[j].amount {
+					return list[i].amount > list[j].amount
+				}
+				return list[i].person < list[j].person
+			})
+		}
+
+		amount := creditors[0].amount
+		if debtors[0].amount < amount {
+			amount = debtors[0].amount
+		}
+		payments = append(payments, Payment{From: debtors[0].person, To: creditors[0].person, Amount: amount})
+
+		creditors[0].amount -= amount
+		debtors[0].amount -= amount
+		if creditors[0].amount < 0.005 {
+			creditors = creditors[1:]
+		}
+		if debtors[0].amount < 0.005 {
+			debtors = debtors[1:]
+		}
+	}
+
+	return payments
+}
+
+// sortedPeople returns the people with a balance in name order.
+func sortedPeople(balances map[string]float64) []string {
+	var people []string
+	for person := range balances {
+		people = append(people, person)
+	}
+	sort.Strings(people)
+	return people
+}
+
+// printBalances prints each person's balance and, when verbose, the running
+// balance after every shared expense and settlement.
+func (app *application) printBalances(ledger []LedgerEntry, balances map[string]float64, verbose bool) {
+	people := sortedPeople(balances)
+	if verbose {
+		fmt.Printf("%-10s  %10s  %-24s", "Date", "Amount", "Description")
+		for _, person := range people {
+			fmt.Printf("  %10.10s", person)
+		}
+		fmt.Println()
+		for _, entry := range ledger {
+			fmt.Printf("%-10s  %10.2f  %-24s", entry.Transaction.Date.Format("02-01-2006"), entry.Transaction.Amount, fitLabel(entry.Transaction.Description, 24))
+			for _, person := range people {
+				fmt.Printf("  %10.2f", entry.Balances[person])
+			}
+			fmt.Println()
+		}
+		fmt.Println()
+	}
+
+	for _, person := range people {
+		balance := math.Round(balances[person]*100) / 100
+		switch {
+		case balance > 0:
+			fmt.Printf("%s is owed %s\n", person, app.money(balance))
+		case balance < 0:
+			fmt.Printf("%s owes %s\n", person, app.money(-balance))
+		default:
+			fmt.Printf("%s is settled up\n", person)
+		}
+	}
+}
+
+// shareCommand marks expenses as shared, records settlements and reports who
+// owes whom.
+func (app *application) shareCommand(args []string) error {
+	usage := errors.New("usage: share add|remove|paid|balances|settleup [filters]")
+	if len(args) == 0 {
+		return usage
+	}
+	action := args[0]
+
+	fs, src := newFlagSet("share " + action)
+	filters := addFilterFlags(fs)
+	with := fs.String("with", "", "who the expenses are shared with, separated by comma.\nFollow each name with :percent or :amount for those splits, e.g. Sam:40")
+	method := fs.String("split", SplitEqual, "how to split: equal, percent or exact.\nThe payer's share is whatever the others' leave")
+	paidBy := fs.String("paidby", "", "who paid the expenses.\nDefaults to me from the config, or Me")
+	person := fs.String("person", "", "who the settlement was paid to or received from")
+	verbose := fs.Bool("v", false, "print the running balance after every shared expense")
+	dryRun := fs.Bool("n", false, "list the transactions that would change without saving")
+	fs.Parse(args[1:])
+
+	var sharing *Sharing
+	switch action {
+	case "add":
+		if *method != SplitEqual && *method != SplitPercent && *method != SplitExact {
+			return fmt.Errorf("unknown split %q: use %s, %s or %s", *method, SplitEqual, SplitPercent, SplitExact)
+		}
+		shares, err := parseShares(*with, *method)
+		if err != nil {
+			return err
+		}
+		if *paidBy == "" {
+			*paidBy = app.me()
+		}
+		// The payer's share is the remainder, so listing them too would
+		// count it twice
+		for _, share := range shares {
+			if strings.EqualFold(share.Person, *paidBy) {
+				return fmt.Errorf("%s paid, so leave them out of -with: their share is whatever the others' leave", share.Person)
+			}
+		}
+		sharing = &Sharing{PaidBy: *paidBy, Method: *method, Shares: shares}
+	case "paid":
+		if *person == "" {
+			return errors.New("please name who the settlement was paid to or received from with -person")
+		}
+	case "remove", "balances", "settleup":
+	default:
+		return usage
+	}
+
+	if err := app.load(src); err != nil {
+		return err
+	}
+	if err := app.applyFilters(filters); err != nil {
+		return err
+	}
+
+	switch action {
+	case "add", "remove":
+		return app.editTransactions(*app.transactions, *dryRun, func(edit *Edit, transaction Transaction) bool {
+			if transaction.Type != Expense || (sharing == nil && edit.Sharing == nil) {
+				return false
+			}
+			edit.Sharing = sharing
+			return true
+		})
+	case "paid":
+		return app.editTransactions(*app.transactions, *dryRun, func(edit *Edit, transaction Transaction) bool {
+			// Money coming in was paid by them, money going out by me
+			settlement := &Settlement{From: *person, To: app.me()}
+			if transaction.Type == Expense {
+				settlement = &Settlement{From: app.me(), To: *person}
+			}
+			edit.Settlement = settlement
+			return true
+		})
+	}
+
+	ledger, balances, err := calculateBalances(*app.transactions)
+	if err != nil {
+		return err
+	}
+	if len(ledger) == 0 {
+		fmt.Println("No shared expenses")
+		return nil
+	}
+
+	if action == "balances" {
+		app.printBalances(ledger, balances, *verbose)
+		return nil
+	}
+
+	payments := settleUp(balances)
+	if len(payments) == 0 {
+		fmt.Println("Everyone is settled up")
+	}
+	for _, payment := range payments {
+		fmt.Printf("%s pays %s %s\n", payment.From, payment.To, app.money(payment.Amount))
+	}
+	return nil
+}
+
+// editTransactions changes the edit of every transaction, or of the one it was
+// split from, and saves the edits. change reports whether it changed the edit.
+func (app *application) editTransactions(transactions Transactions, dryRun bool, change func(edit *Edit, transaction Transaction) bool) error {
+	changed := make(map[string]bool)
+	for _, transaction := range transactions {
+		id := splitParent(transaction.ID)
+		if changed[id] {
+			continue
+		}
+
+		edit := app.edits[id]
+		if !change(&edit, transaction) {
+			continue
+		}
+		app.edits[id] = edit
+		changed[id] = true
+		fmt.Printf("  %s  %10.2f  %s\n", transaction.Date.Format("02-01-2006"), transaction.Amount, transaction.Description)
+	}
+
+	if dryRun {
+		fmt.Printf("Would have changed %d transactions\n", len(changed))
+		return nil
+	}
+
+	if len(changed) > 0 {
+		if err := saveEdits(app.editsFile, app.edits); err != nil {
+			return fmt.Errorf("unable to save edits: %w", err)
+		}
+	}
+	fmt.Printf("Changed %d transactions\n", len(changed))
+	return nil
+}
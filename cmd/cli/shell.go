@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// shellFilter is one filter on the shell's stack, named after the CLI flag it
+// behaves like.
+type shellFilter struct {
+	kind string
+	arg  string
+}
+
+func (f shellFilter) String() string {
+	return fmt.Sprintf("%s %q", f.kind, f.arg)
+}
+
+// shellFilterKinds describes each kind of filter for the help text.
+var shellFilterKinds = [][2]string{
+	{"in", "include descriptions matching these terms, like -in"},
+	{"ex", "exclude descriptions matching these terms, like -ex"},
+	{"ga", "include amounts greater or equal than this, like -ga"},
+	{"la", "include amounts less or equal than this, like -la"},
+	{"gd", "include dates on or after this, like -gd"},
+	{"ld", "include dates on or before this, like -ld"},
+	{"md", "include dates between these two, or in a span such as last-month, like -md"},
+	{"acct", "include these accounts, like -acct"},
+	{"cat", "include these categories"},
+	{"tag", "include tags matching this, like -tag"},
+}
+
+// shell is an interactive session over transactions imported once. Filters
+// stack on top of each other, and every change to the stack can be undone.
+type shell struct {
+	app     *application
+	base    Transactions
+	filters []shellFilter
+	// undo holds earlier filter stacks, most recent last.
+	undo [][]shellFilter
+}
+
+// current returns the transactions left after applying every filter.
+func (s *shell) current() Transactions {
+	transactions := s.base
+	s.app.transactions = &transactions
+	for _, filter := range s.filters {
+		s.apply(filter)
+	}
+	return *s.app.transactions
+}
+
+// apply narrows the application's transactions with one filter.
+func (s *shell) apply(filter shellFilter) {
+	app := s.app
+	switch filter.kind {
+	case "in":
+		app.handleIncludeTransactionsFlag(filter.arg)
+	case "ex":
+		app.handleExcludeTransactionsFlag(filter.arg)
+	case "ga":
+		amount, _ := strconv.ParseFloat(filter.arg, 64)
+		app.handleGreaterAmountFlag(amount)
+	case "la":
+		amount, _ := strconv.ParseFloat(filter.arg, 64)
+		app.handleLesserAmountFlag(amount)
+	case "gd":
+		app.handleGreaterDateFlag(filter.arg)
+	case "ld":
+		app.handleLesserDateFlag(filter.arg)
+	case "md":
+		app.handleMiddleDateFlag(strings.Split(filter.arg, ","))
+	case "acct":
+		app.handleAccountFlag(filter.arg)
+	case "cat":
+		filtered := filterCategories(*app.transactions, filter.arg)
+		app.transactions = &filtered
+	case "tag":
+		app.handleTagFlag(filter.arg)
+	}
+}
+
+// newFilter checks a filter's argument before it goes on the stack, so that
+// applying the stack never fails.
+func (s *shell) newFilter(kind, arg string) (shellFilter, error) {
+	filter := shellFilter{kind: kind, arg: arg}
+	if arg == "" {
+		return filter, errors.New("usage: filter <kind> <value>")
+	}
+
+	switch kind {
+	case "in", "ex", "acct", "cat":
+	case "tag":
+		if err := validTagPredicate(arg); err != nil {
+			return filter, err
+		}
+	case "ga", "la":
+		if _, err := strconv.ParseFloat(arg, 64); err != nil {
+			return filter, fmt.Errorf("invalid amount %q", arg)
+		}
+	case "gd", "ld":
+		if _, _, err := s.app.parseDateRange(arg); err != nil {
+			return filter, err
+		}
+	case "md":
+		dates := strings.Split(arg, ",")
+		if len(dates) > 2 {
+			return filter, errors.New("please provide two dates separated by comma")
+		}
+		for _, date := range dates {
+			if _, _, err := s.app.parseDateRange(date); err != nil {
+				return filter, err
+			}
+		}
+	default:
+		return filter, fmt.Errorf("unknown filter %q", kind)
+	}
+
+	return filter, nil
+}
+
+// setFilters replaces the filter stack, remembering the old one for undo.
+func (s *shell) setFilters(filters []shellFilter) {
+	s.undo = append(s.undo, s.filters)
+	s.filters = filters
+}
+
+// splitArgs splits a command line on spaces, keeping double quoted text
+// together.
+func splitArgs(line string) []string {
+	var args []string
+	var arg strings.Builder
+	quoted, started := false, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case r == ' ' && !quoted:
+			if started {
+				args = append(args, arg.String())
+				arg.Reset()
+				started = false
+			}
+		default:
+			arg.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, arg.String())
+	}
+
+	return args
+}
+
+// run executes one command and reports whether the session should end.
+func (s *shell) run(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	transactions := s.current()
+	s.app.transactions = &transactions
+
+	// Reports need something to report on
+	switch args[0] {
+	case "trends", "summary", "group", "export":
+		if len(transactions) == 0 {
+			return false, errors.New("no transactions match the filters")
+		}
+	}
+
+	switch args[0] {
+	case "quit", "exit":
+		return true, nil
+	case "help":
+		s.printHelp()
+	case "filter":
+		if len(args) < 3 {
+			return false, errors.New("usage: filter <kind> <value>")
+		}
+		filter, err := s.newFilter(args[1], strings.Join(args[2:], " "))
+		if err != nil {
+			return false, err
+		}
+		s.setFilters(append(append([]shellFilter{}, s.filters...), filter))
+		fmt.Printf("%d transactions\n", len(s.current()))
+	case "pop":
+		if len(s.filters) == 0 {
+			return false, errors.New("there are no filters to pop")
+		}
+		index := len(s.filters)
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 || n > len(s.filters) {
+				return false, fmt.Errorf("filter number must be between 1 and %d", len(s.filters))
+			}
+			index = n
+		}
+		filters := append([]shellFilter{}, s.filters[:index-1]...)
+		s.setFilters(append(filters, s.filters[index:]...))
+		fmt.Printf("%d transactions\n", len(s.current()))
+	case "clear":
+		s.setFilters(nil)
+		fmt.Printf("%d transactions\n", len(s.current()))
+	case "undo":
+		if len(s.undo) == 0 {
+			return false, errors.New("nothing to undo")
+		}
+		s.filters = s.undo[len(s.undo)-1]
+		s.undo = s.undo[:len(s.undo)-1]
+		fmt.Printf("%d transactions\n", len(s.current()))
+	case "filters", "history":
+		if len(s.filters) == 0 {
+			fmt.Println("No filters")
+		}
+		base := s.base
+		s.app.transactions = &base
+		for i, filter := range s.filters {
+			s.apply(filter)
+			fmt.Printf("  %d. %s (%d transactions)\n", i+1, filter, len(*s.app.transactions))
+		}
+	case "list":
+		limit := 20
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				return false, fmt.Errorf("invalid number %q", args[1])
+			}
+			limit = n
+		}
+		for i, transaction := range transactions {
+			if i == limit {
+				fmt.Printf("  ... %d more\n", len(transactions)-limit)
+				break
+			}
+			fmt.Printf("  %s  %10.2f  %-14s  %s\n", transaction.Date.Format("02-01-2006"), transaction.Amount, transaction.Category, transaction.Description)
+		}
+	case "trends":
+		topX := 10
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				return false, fmt.Errorf("invalid number %q", args[1])
+			}
+			topX = n
+		}
+		// "trends 10 periods" splits the trends into 4 week periods like -tx
+		s.app.printRestatement()
+		s.app.printTopTrends(topX, len(args) > 2 && args[2] == "periods")
+	case "summary":
+		s.app.printRestatement()
+		s.app.handleTotalExpensesFlag()
+	case "group":
+		if len(args) < 2 {
+			return false, fmt.Errorf("usage: group by <%s>", strings.Join(groupBy, "|"))
+		}
+		groups, err := groupTransactions(transactions, args[len(args)-1])
+		if err != nil {
+			return false, err
+		}
+		s.app.printGroups(groups)
+	case "export":
+		if len(args) < 2 {
+			return false, errors.New("usage: export <filename>")
+		}
+		if err := exportCSV(args[1], transactions); err != nil {
+			return false, err
+		}
+		fmt.Printf("Exported %d transactions to %s\n", len(transactions), args[1])
+	default:
+		return false, fmt.Errorf("unknown command %q: type help for a list of commands", args[0])
+	}
+
+	return false, nil
+}
+
+func (s *shell) printHelp() {
+	fmt.Println("Commands:")
+	fmt.Println("  filter <kind> <value>  add a filter to the stack")
+	for _, kind := range shellFilterKinds {
+		fmt.Printf("      %-5s %s\n", kind[0], kind[1])
+	}
+	fmt.Println("  filters                show the filter stack")
+	fmt.Println("  pop [n]                remove the last filter, or filter n")
+	fmt.Println("  clear                  remove every filter")
+	fmt.Println("  undo                   undo the last change to the filters")
+	fmt.Println("  list [n]               list the first n transactions")
+	fmt.Println("  trends [n] [periods]   print the top n trends, split into periods like -tx")
+	fmt.Println("  summary                print total expenses, income and savings rate")
+	fmt.Printf("  group by <field>       total by %s\n", strings.Join(groupBy, ", "))
+	fmt.Println("  export <file>          write the filtered transactions to a CSV file")
+	fmt.Println("  quit                   leave the shell")
+}
+
+// shellCommand starts an interactive session over the imported transactions.
+func (app *application) shellCommand(args []string) error {
+	fs, src := newFlagSet("shell")
+	cpiFlags := addRealFlags(fs)
+	fs.Parse(args)
+
+	if err := app.load(src); err != nil {
+		return err
+	}
+	if err := app.restate(*cpiFlags.cpi, *cpiFlags.base); err != nil {
+		return err
+	}
+
+	s := &shell{app: app, base: *app.transactions}
+	fmt.Printf("Loaded %d transactions. Type help for a list of commands.\n", len(s.base))
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Printf("fineants [%d]> ", len(s.filters))
+		if !scanner.Scan() {
+			fmt.Println()
+			return scanner.Err()
+		}
+
+		quit, err := s.run(splitArgs(strings.TrimSpace(scanner.Text())))
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// TagRule adds tags to transactions whose description contains any of its
+// "|" separated terms, matched the same way as -in and -ex.
+type TagRule struct {
+	Terms string
+	Tags  []string
+}
+
+// loadTagRules reads tag rules from a CSV file of terms,tag[,tag...] rows.
+// Unlike categories, every matching rule adds its tags.
+func loadTagRules(filename string) ([]TagRule, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []TagRule
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "terms") {
+			continue
+		}
+		rule := TagRule{}
+		if len(record) > 0 {
+			rule.Terms = strings.TrimSpace(record[0])
+		}
+		for _, tag := range record[1:] {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				rule.Tags = append(rule.Tags, tag)
+			}
+		}
+		if rule.Terms == "" || len(rule.Tags) == 0 {
+			return nil, fmt.Errorf("%s line %d: expected terms and at least one tag", filename, i+1)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// tagTransactions adds the tags of every matching rule to each transaction.
+func tagTransactions(transactions Transactions, rules []TagRule) {
+	for i := range transactions {
+		for _, rule := range rules {
+			if matchesAny(transactions[i].Description, rule.Terms) {
+				transactions[i].Tags = addTags(transactions[i].Tags, rule.Tags...)
+			}
+		}
+	}
+}
+
+// containsTag reports whether the tags include the tag, ignoring case.
+func containsTag(tags []string, tag string) bool {
+	for _, existing := range tags {
+		if strings.EqualFold(existing, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// addTags appends the tags that aren't already present, ignoring case.
+func addTags(tags []string, add ...string) []string {
+	for _, tag := range add {
+		if !containsTag(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// removeTags returns the tags without any of those given, ignoring case.
+func removeTags(tags []string, remove ...string) []string {
+	var kept []string
+	for _, tag := range tags {
+		if !containsTag(remove, tag) {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+// hasTag reports whether the tags include one matching the pattern, ignoring
+// case. Patterns may use * wildcards, so holiday-* matches holiday-2024 and *
+// matches any tag.
+func hasTag(tags []string, pattern string) bool {
+	pattern = strings.ToLower(pattern)
+	for _, tag := range tags {
+		if matched, _ := path.Match(pattern, strings.ToLower(tag)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesTags reports whether the tags satisfy a tag predicate. A predicate is
+// "|" separated alternatives, any of which may match. Each alternative is "&"
+// separated patterns that must all match, and a pattern starting with ! must
+// not match. For example "holiday-*&!reimbursable|wedding", or "!*" for
+// untagged transactions.
+func matchesTags(tags []string, predicate string) bool {
+	for _, alternative := range strings.Split(predicate, "|") {
+		matched := true
+		for _, pattern := range strings.Split(alternative, "&") {
+			pattern = strings.TrimSpace(pattern)
+			negated := strings.HasPrefix(pattern, "!")
+			pattern = strings.TrimPrefix(pattern, "!")
+			if pattern == "" {
+				continue
+			}
+			if hasTag(tags, pattern) == negated {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validTagPredicate checks a predicate's patterns are well formed.
+func validTagPredicate(predicate string) error {
+	for _, alternative := range strings.Split(predicate, "|") {
+		for _, pattern := range strings.Split(alternative, "&") {
+			pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "!")
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid tag pattern %q", pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// filterTags returns the transactions whose tags satisfy the predicate.
+func filterTags(transactions Transactions, predicate string) Transactions {
+	var filtered Transactions
+	for _, transaction := range transactions {
+		if matchesTags(transaction.Tags, predicate) {
+			filtered = append(filtered, transaction)
+		}
+	}
+
+	return filtered
+}
+
+// -tag flag
+func (app *application) handleTagFlag(predicate string) error {
+	if predicate != "" {
+		if err := validTagPredicate(predicate); err != nil {
+			return err
+		}
+		transactions := filterTags(*app.transactions, predicate)
+		app.transactions = &transactions
+	}
+	return nil
+}
+
+// filterCategories returns the transactions in any of the "|" separated
+// categories, ignoring case.
+func filterCategories(transactions Transactions, categories string) Transactions {
+	var filtered Transactions
+	for _, transaction := range transactions {
+		for _, category := range strings.Split(categories, "|") {
+			if strings.EqualFold(transaction.Category, strings.TrimSpace(category)) {
+				filtered = append(filtered, transaction)
+				break
+			}
+		}
+	}
+
+	return filtered
+}
+
+// printTagReport prints each tag's totals and the dates it spans.
+func (app *application) printTagReport(groups []Group) {
+	nameWidth := len("Tag")
+	for _, group := range groups {
+		if len(group.Name) > nameWidth {
+			nameWidth = len(group.Name)
+		}
+	}
+
+	fmt.Printf("%-*s  %6s  %12s  %12s  %12s  %-10s  %-10s  %5s\n", nameWidth, "Tag", "Count", "Expenses", "Income", "Total", "First", "Last", "Days")
+	for _, group := range groups {
+		days := int(group.Last.Sub(group.First).Hours()/24+0.5) + 1
+		fmt.Printf("%-*s  %6d  %12.2f  %12.2f  %12.2f  %-10s  %-10s  %5d\n",
+			nameWidth, group.Name, group.Count, group.Expenses, group.Income, group.Income-group.Expenses,
+			group.First.Format("02-01-2006"), group.Last.Format("02-01-2006"), days)
+	}
+}
+
+// tagCommand tags or untags the transactions matching a set of filters, or
+// reports totals by tag.
+func (app *application) tagCommand(args []string) error {
+	usage := errors.New("usage: tag add|remove [filters] <tag>... | tag report [filters]")
+	if len(args) == 0 {
+		return usage
+	}
+	action := args[0]
+
+	fs, src := newFlagSet("tag " + action)
+	filters := addFilterFlags(fs)
+	dryRun := fs.Bool("n", false, "list the transactions that would change without saving")
+	fs.Parse(args[1:])
+
+	switch action {
+	case "add", "remove":
+		if fs.NArg() == 0 {
+			return usage
+		}
+	case "report":
+	default:
+		return usage
+	}
+
+	if err := app.load(src); err != nil {
+		return err
+	}
+	if err := app.applyFilters(filters); err != nil {
+		return err
+	}
+
+	if len(*app.transactions) == 0 {
+		return errors.New("no transactions found")
+	}
+
+	if action == "report" {
+		groups, err := groupTransactions(*app.transactions, "tag")
+		if err != nil {
+			return err
+		}
+		if len(groups) == 0 {
+			fmt.Println("No tagged transactions")
+			return nil
+		}
+		app.printTagReport(groups)
+		return nil
+	}
+
+	return app.bulkTag(*app.transactions, fs.Args(), action == "remove", *dryRun)
+}
+
+// bulkTag adds or removes tags on every transaction and saves the edits.
+// Tags added by tag rules can't be removed this way, since the rule adds them
+// again on the next import.
+func (app *application) bulkTag(transactions Transactions, tags []string, remove, dryRun bool) error {
+	changed := make(map[string]bool)
+	for _, transaction := range transactions {
+		id := splitParent(transaction.ID)
+		if changed[id] {
+			continue
+		}
+
+		edit := app.edits[id]
+		before := len(edit.Tags)
+		if remove {
+			edit.Tags = removeTags(edit.Tags, tags...)
+		} else {
+			edit.Tags = addTags(edit.Tags, tags...)
+		}
+		if len(edit.Tags) == before {
+			continue
+		}
+
+		changed[id] = true
+		app.edits[id] = edit
+		fmt.Printf("  %s  %10.2f  %s\n", transaction.Date.Format("02-01-2006"), transaction.Amount, transaction.Description)
+	}
+
+	verb := "Tagged"
+	if remove {
+		verb = "Untagged"
+	}
+	if dryRun {
+		fmt.Printf("Would have %s %d transactions\n", strings.ToLower(verb), len(changed))
+		return nil
+	}
+
+	if len(changed) > 0 {
+		if err := saveEdits(app.editsFile, app.edits); err != nil {
+			return fmt.Errorf("unable to save edits: %w", err)
+		}
+	}
+	fmt.Printf("%s %d transactions with %s\n", verb, len(changed), strings.Join(tags, ", "))
+	return nil
+}
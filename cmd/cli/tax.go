@@ -0,0 +1,344 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed tax.html
+var taxTemplate string
+
+// TaxConfig sets how the tax-year report treats gains, income and expenses.
+type TaxConfig struct {
+	// CGTDiscount is the percentage of a gain on a holding kept over 12
+	// months that is not taxed.
+	CGTDiscount float64 `yaml:"cgt_discount"`
+	// InterestCategory is the category of interest earned, Interest unless
+	// set.
+	InterestCategory string `yaml:"interest_category"`
+	// DeductibleTag marks deductible expenses, deductible unless set.
+	DeductibleTag string `yaml:"deductible_tag"`
+}
+
+// CapitalGain is a disposal made in the tax year.
+type CapitalGain struct {
+	Disposal
+	Days int
+	// Discountable is set on gains from holdings kept over 12 months.
+	Discountable bool
+}
+
+// TaxLine is an amount of income or deductions under a label.
+type TaxLine struct {
+	Label  string
+	Amount float64
+}
+
+// TaxReport is everything an accountant needs for one tax year.
+type TaxReport struct {
+	Year            DateRange
+	Discount        float64
+	Gains           []CapitalGain
+	CapitalGains    float64
+	CapitalLosses   float64
+	DiscountApplied float64
+	NetCapitalGain  float64
+	Dividends       []TaxLine
+	Interest        []TaxLine
+	Deductions      []TaxLine
+}
+
+// calculateCapitalGains lists the disposals in the year and works out the net
+// capital gain. Losses are taken off gains that can't be discounted first,
+// then the discount applies to what is left of the discountable gains.
+func (r *TaxReport) calculateCapitalGains(disposals []Disposal) {
+	var discountable, other float64
+	for _, disposal := range disposals {
+		if !r.Year.Contains(disposal.Sold) {
+			continue
+		}
+
+		gain := CapitalGain{
+			Disposal:     disposal,
+			Days:         int(math.Round(disposal.Sold.Sub(disposal.Acquired).Hours() / 24)),
+			Discountable: calendarDay(disposal.Sold) > calendarDay(disposal.Acquired.AddDate(1, 0, 0)),
+		}
+		gain.Discountable = gain.Discountable && disposal.Gain() > 0
+		r.Gains = append(r.Gains, gain)
+
+		switch {
+		case disposal.Gain() < 0:
+			r.CapitalLosses -= disposal.Gain()
+		case gain.Discountable:
+			discountable += disposal.Gain()
+		default:
+			other += disposal.Gain()
+		}
+		if disposal.Gain() > 0 {
+			r.CapitalGains += disposal.Gain()
+		}
+	}
+
+	losses := r.CapitalLosses
+	offset := math.Min(losses, other)
+	other -= offset
+	losses -= offset
+	discountable -= math.Min(losses, discountable)
+
+	r.DiscountApplied = discountable * r.Discount / 100
+	r.NetCapitalGain = other + discountable - r.DiscountApplied
+}
+
+// sumLines adds up the amounts of some tax lines.
+func sumLines(lines []TaxLine) float64 {
+	total := 0.0
+	for _, line := range lines {
+		total += line.Amount
+	}
+	return total
+}
+
+// linesByLabel totals amounts by label, largest first.
+func linesByLabel(amounts map[string]float64) []TaxLine {
+	var lines []TaxLine
+	for label, amount := range amounts {
+		lines = append(lines, TaxLine{Label: label, Amount: amount})
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Amount != lines[j].Amount {
+			return lines[i].Amount > lines[j].Amount
+		}
+		return lines[i].Label < lines[j].Label
+	})
+	return lines
+}
+
+// calculateIncomeAndDeductions totals the year's dividends by security,
+// interest by payer and deductible expenses by category.
+func (app *application) calculateIncomeAndDeductions(report *TaxReport, income []Trade, transactions Transactions) {
+	dividends := make(map[string]float64)
+	for _, trade := range income {
+		if report.Year.Contains(trade.Date) {
+			dividends[trade.Symbol] += trade.Value()
+		}
+	}
+	report.Dividends = linesByLabel(dividends)
+
+	interestCategory := app.config.Tax.InterestCategory
+	if interestCategory == "" {
+		interestCategory = "Interest"
+	}
+	deductibleTag := app.config.Tax.DeductibleTag
+	if deductibleTag == "" {
+		deductibleTag = "deductible"
+	}
+
+	interest := make(map[string]float64)
+	deductions := make(map[string]float64)
+	for _, transaction := range report.Year.Filter(transactions) {
+		switch {
+		case transaction.Type == Income && strings.EqualFold(transaction.Category, interestCategory):
+			interest[transaction.Description] += transaction.Amount
+		case transaction.Type == Expense && containsTag(transaction.Tags, deductibleTag):
+			category := transaction.Category
+			if category == "" {
+				category = "Uncategorized"
+			}
+			deductions[category] += -transaction.Amount - transaction.Reimbursed
+		}
+	}
+	report.Interest = linesByLabel(interest)
+	report.Deductions = linesByLabel(deductions)
+}
+
+// printTaxReport prints the report as text.
+func (app *application) printTaxReport(report TaxReport) {
+	fmt.Printf("Tax year %s\n", report.Year)
+
+	if len(report.Gains) > 0 {
+		fmt.Println()
+		fmt.Println("Capital gains")
+		fmt.Printf("  %-8s  %-10s  %-10s  %6s  %12s  %12s  %12s  %12s  %s\n", "Symbol", "Acquired", "Disposed", "Days", "Quantity", "Proceeds", "Cost", "Gain", "Discount")
+		for _, gain := range report.Gains {
+			eligible := ""
+			if gain.Discountable {
+				eligible = "eligible"
+			}
+			fmt.Printf("  %-8s  %-10s  %-10s  %6d  %12.4f  %12.2f  %12.2f  %12.2f  %s\n",
+				gain.Symbol, gain.Acquired.Format("02-01-2006"), gain.Sold.Format("02-01-2006"), gain.Days,
+				gain.Quantity, gain.Proceeds, gain.Cost, gain.Gain(), eligible)
+		}
+		fmt.Printf("  %-30s  %12.2f\n", "Capital gains", report.CapitalGains)
+		fmt.Printf("  %-30s  %12.2f\n", "Capital losses", report.CapitalLosses)
+		fmt.Printf("  %-30s  %12.2f\n", fmt.Sprintf("Discount applied (%g%%)", report.Discount), report.DiscountApplied)
+		fmt.Printf("  %-30s  %12.2f\n", "Net capital gain", report.NetCapitalGain)
+	}
+
+	for _, section := range []struct {
+		title string
+		lines []TaxLine
+	}{
+		{"Dividends", report.Dividends},
+		{"Interest", report.Interest},
+		{"Deductible expenses", report.Deductions},
+	} {
+		if len(section.lines) == 0 {
+			continue
+		}
+		fmt.Println()
+		fmt.Println(section.title)
+		for _, line := range section.lines {
+			fmt.Printf("  %-30s  %12.2f\n", fitLabel(line.Label, 30), line.Amount)
+		}
+		fmt.Printf("  %-30s  %12.2f\n", "Total", sumLines(section.lines))
+	}
+}
+
+// writeTaxCSV writes the report as CSV, one row per gain, income or
+// deduction line.
+func writeTaxCSV(filename string, report TaxReport) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	money := func(amount float64) string {
+		return strconv.FormatFloat(amount, 'f', 2, 64)
+	}
+
+	rows := [][]string{{"section", "item", "acquired", "disposed", "days", "quantity", "proceeds", "cost", "amount", "discount_eligible"}}
+	for _, gain := range report.Gains {
+		rows = append(rows, []string{
+			"capital gain", gain.Symbol, gain.Acquired.Format("2006-01-02"), gain.Sold.Format("2006-01-02"),
+			strconv.Itoa(gain.Days), strconv.FormatFloat(gain.Quantity, 'f', -1, 64),
+			money(gain.Proceeds), money(gain.Cost), money(gain.Gain()), strconv.FormatBool(gain.Discountable),
+		})
+	}
+	if len(report.Gains) > 0 {
+		rows = append(rows, []string{"net capital gain", "", "", "", "", "", "", "", money(report.NetCapitalGain), ""})
+	}
+	for _, section := range []struct {
+		name  string
+		lines []TaxLine
+	}{
+		{"dividend", report.Dividends},
+		{"interest", report.Interest},
+		{"deduction", report.Deductions},
+	} {
+		for _, line := range section.lines {
+			rows = append(rows, []string{section.name, line.Label, "", "", "", "", "", "", money(line.Amount), ""})
+		}
+	}
+
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// writeTaxHTML writes the report as a printable HTML page.
+func (app *application) writeTaxHTML(filename string, report TaxReport) error {
+	tmpl, err := template.New("tax").Funcs(template.FuncMap{
+		"money": app.money,
+		"date":  func(t time.Time) string { return t.Format("02-01-2006") },
+		"total": sumLines,
+	}).Parse(taxTemplate)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, report); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// taxCommand reports a tax year's capital gains, investment and interest
+// income and deductible expenses.
+func (app *application) taxCommand(args []string) error {
+	fs, src := newFlagSet("tax")
+	year := fs.String("year", "last-fy", "the tax year, such as fy2024 or this-fy")
+	tradesFile := fs.String("trades", "", "CSV of trades, as for invest")
+	pricesFile := fs.String("prices", "", "CSV of prices, as for invest")
+	method := fs.String("method", "", "cost basis method: fifo or average")
+	csvFile := fs.String("csv", "", "write the report as CSV to this file")
+	htmlFile := fs.String("html", "", "write the report as printable HTML to this file")
+	fs.Parse(args)
+
+	// Transactions are only needed for interest and deductions
+	var transactions Transactions
+	if *src.filename != "" || *src.accounts != "" || app.config.File != "" || app.config.Accounts != "" {
+		if err := app.load(src); err != nil {
+			return err
+		}
+		transactions = *app.transactions
+	} else if err := app.setTimezone(*src.timezone); err != nil {
+		return err
+	}
+
+	start, end, err := app.parseDateRange(*year)
+	if err != nil {
+		return err
+	}
+	report := TaxReport{Year: inclusiveRange(start, end), Discount: app.config.Tax.CGTDiscount}
+
+	var income []Trade
+	if *tradesFile != "" || app.config.Investments.Trades != "" {
+		if *method == "" {
+			*method = app.config.Investments.Method
+		}
+		if *method = strings.ToLower(*method); *method == "" {
+			*method = FIFO
+		}
+		if *method != FIFO && *method != Average {
+			return fmt.Errorf("unknown cost basis method %q: use fifo or average", *method)
+		}
+		trades, _, err := app.loadInvestments(*tradesFile, *pricesFile)
+		if err != nil {
+			return err
+		}
+		portfolio, err := buildPortfolio(trades, *method, end)
+		if err != nil {
+			return err
+		}
+		report.calculateCapitalGains(portfolio.Disposals)
+		income = portfolio.Income
+	}
+
+	if transactions == nil && income == nil && len(report.Gains) == 0 {
+		return errors.New("nothing to report: give transactions with -f or -accounts, or trades with -trades")
+	}
+	app.calculateIncomeAndDeductions(&report, income, transactions)
+
+	app.printTaxReport(report)
+	if *csvFile != "" {
+		if err := writeTaxCSV(*csvFile, report); err != nil {
+			return fmt.Errorf("unable to write %s: %w", *csvFile, err)
+		}
+		fmt.Printf("\nWrote %s\n", *csvFile)
+	}
+	if *htmlFile != "" {
+		if err := app.writeTaxHTML(*htmlFile, report); err != nil {
+			return fmt.Errorf("unable to write %s: %w", *htmlFile, err)
+		}
+		fmt.Printf("\nWrote %s\n", *htmlFile)
+	}
+	return nil
+}
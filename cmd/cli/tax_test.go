@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalculateCapitalGains(t *testing.T) {
+	year := inclusiveRange(date(2023, 7, 1), date(2024, 6, 30))
+	long := func(gain float64) Disposal {
+		return Disposal{Symbol: "LONG", Acquired: date(2022, 1, 1), Sold: date(2024, 1, 1), Proceeds: 1000 + gain, Cost: 1000}
+	}
+	short := func(gain float64) Disposal {
+		return Disposal{Symbol: "SHORT", Acquired: date(2023, 10, 1), Sold: date(2024, 1, 1), Proceeds: 1000 + gain, Cost: 1000}
+	}
+
+	tests := []struct {
+		name          string
+		disposals     []Disposal
+		wantGains     float64
+		wantLosses    float64
+		wantDiscount  float64
+		wantNetGain   float64
+		wantDisposals int
+	}{
+		{"discount", []Disposal{long(1000)}, 1000, 0, 500, 500, 1},
+		// The loss uses up the 400 short gain, then 200 of the long gain is
+		// left to discount
+		{"losses before discount", []Disposal{long(1000), short(400), short(-600)}, 1400, 600, 400, 400, 3},
+		{"losses over gains", []Disposal{long(100), short(-300)}, 100, 300, 0, 0, 2},
+		{"held exactly a year", []Disposal{{Symbol: "YEAR", Acquired: date(2023, 1, 1), Sold: date(2024, 1, 1), Proceeds: 1500, Cost: 1000}}, 500, 0, 0, 500, 1},
+		{"outside the year", []Disposal{long(1000), {Symbol: "OLD", Acquired: date(2020, 1, 1), Sold: date(2023, 6, 30), Proceeds: 5000, Cost: 1000}}, 1000, 0, 500, 500, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := TaxReport{Year: year, Discount: 50}
+			report.calculateCapitalGains(tt.disposals)
+
+			for _, check := range []struct {
+				label     string
+				got, want float64
+			}{
+				{"capital gains", report.CapitalGains, tt.wantGains},
+				{"capital losses", report.CapitalLosses, tt.wantLosses},
+				{"discount", report.DiscountApplied, tt.wantDiscount},
+				{"net capital gain", report.NetCapitalGain, tt.wantNetGain},
+			} {
+				if math.Abs(check.got-check.want) > 1e-9 {
+					t.Errorf("%s = %.2f, want %.2f", check.label, check.got, check.want)
+				}
+			}
+			if len(report.Gains) != tt.wantDisposals {
+				t.Errorf("%d gains listed, want %d", len(report.Gains), tt.wantDisposals)
+			}
+		})
+	}
+}
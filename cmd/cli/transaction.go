@@ -2,6 +2,8 @@ package main
 
 import (
 	"encoding/csv"
+	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -16,15 +18,42 @@ const (
 )
 
 type Transaction struct {
+	// ID identifies the transaction across imports of the same file.
+	ID          string
 	Date        time.Time
 	Amount      float64
 	Description string
 	Type        TransactionType
+	// Balance is the bank's running balance after this transaction, when the
+	// CSV provides one. HasBalance reports whether it did.
+	Balance    float64
+	HasBalance bool
+	// Account is the name of the account the transaction was imported from.
+	Account string
+	// Category is set by the category rules, if any were given.
+	Category string
+	Tags     []string
+	// Excluded transactions are left out of reports.
+	Excluded bool
+	// Reimburse names who is expected to pay this expense back, and
+	// Reimbursed is how much they have paid so far.
+	Reimburse  string
+	Reimbursed float64
+	// Reimbursement is how much of this incoming payment pays back expenses.
+	Reimbursement float64
+	// Sharing is set on expenses shared between people, and Settlement on
+	// payments between them.
+	Sharing    *Sharing
+	Settlement *Settlement
 }
 
 type Transactions []Transaction
 
-func importCSV(filename string) (Transactions, error) {
+// importCSV reads transactions from a bank's CSV export laid out as the
+// profile describes. Unless the profile gives a date format, the format is
+// detected from every date in the file, so day first and month first dates
+// are told apart even when the first few could be either.
+func importCSV(filename string, profile ImportProfile) (Transactions, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
@@ -32,16 +61,81 @@ func importCSV(filename string) (Transactions, error) {
 	defer file.Close()
 
 	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
+	reader.FieldsPerRecord = -1
+	if delimiter := []rune(profile.Delimiter); len(delimiter) == 1 {
+		reader.Comma = delimiter[0]
+	}
+
+	columns := profile.Date
+	for _, column := range []int{profile.Amount, profile.Description} {
+		if column > columns {
+			columns = column
+		}
+	}
+
+	// Read every record first, remembering its line for error messages
+	var records [][]string
+	var lines []int
+	skipHeader := profile.SkipHeader
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if skipHeader {
+			skipHeader = false
+			continue
+		}
+		if len(record) <= columns {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+
+	layouts := dateLayouts(profile.MonthFirst)
+	location := profile.location
+	if location == nil {
+		location = time.Local
+	}
+
+	// A header row is skipped even when the profile doesn't say so
+	if len(records) > 0 {
+		_, isDate := parseAnyDate(strings.TrimSpace(records[0][profile.Date]), layouts, location)
+		_, err := strconv.ParseFloat(strings.TrimSpace(records[0][profile.Amount]), 64)
+		if !isDate && err != nil {
+			records, lines = records[1:], lines[1:]
+		}
+	}
+
+	layout := profile.DateFormat
+	if layout == "" && len(records) > 0 {
+		var dates []string
+		for _, record := range records {
+			dates = append(dates, strings.TrimSpace(record[profile.Date]))
+		}
+		layout = detectDateLayout(dates, layouts)
 	}
 
 	var transactions Transactions
-	for _, record := range records {
-		date, _ := time.Parse("02/01/2006", record[0])
-		amount, _ := strconv.ParseFloat(record[1], 64)
-		description := record[2]
+	for i, record := range records {
+		// Timestamps are moved into the location before taking their date
+		date, err := time.ParseInLocation(layout, strings.TrimSpace(record[profile.Date]), location)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: unable to parse date %q", filename, lines[i], record[profile.Date])
+		}
+		date = localDate(date, location)
+		amount, err := strconv.ParseFloat(strings.TrimSpace(record[profile.Amount]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: unable to parse amount %q", filename, lines[i], record[profile.Amount])
+		}
+		description := record[profile.Description]
 		var transactionType TransactionType
 		if amount < 0 {
 			transactionType = Expense
@@ -56,20 +150,70 @@ func importCSV(filename string) (Transactions, error) {
 			Type:        transactionType,
 		}
 
+		// Some banks include the account balance after each transaction
+		if profile.Balance != nil && len(record) > *profile.Balance && strings.TrimSpace(record[*profile.Balance]) != "" {
+			balance, err := strconv.ParseFloat(strings.TrimSpace(record[*profile.Balance]), 64)
+			if err == nil {
+				transaction.Balance = balance
+				transaction.HasBalance = true
+			}
+		}
+
 		transactions = append(transactions, transaction)
 	}
 
 	return transactions, nil
 }
 
-// calculateTotalExpensesAndIncome calculates total expenses and income
+// exportCSV writes transactions to a CSV file in the format importCSV reads.
+func exportCSV(filename string, transactions Transactions) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	for _, transaction := range transactions {
+		record := []string{
+			transaction.Date.Format("02/01/2006"),
+			strconv.FormatFloat(transaction.Amount, 'f', 2, 64),
+			transaction.Description,
+		}
+		if transaction.HasBalance {
+			record = append(record, strconv.FormatFloat(transaction.Balance, 'f', 2, 64))
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// calculateTotalExpensesAndIncome calculates total expenses and income.
+// Reimbursed amounts are netted out of both, since they were never personal
+// spending or earnings. Settlements of shared expenses and money moved in and
+// out of investment accounts are left out.
 func (app *application) calculateTotalExpensesAndIncome() (float64, float64) {
 	var totalExpenses, totalIncome float64
 	for _, transaction := range *app.transactions {
+		if transaction.Settlement != nil {
+			continue
+		}
+		if account, ok := app.account(transaction.Account); ok && account.Type == Investment {
+			continue
+		}
 		if transaction.Type == Income {
-			totalIncome += transaction.Amount
+			totalIncome += transaction.Amount - transaction.Reimbursement
 		} else {
-			totalExpenses += -transaction.Amount
+			totalExpenses += -transaction.Amount - transaction.Reimbursed
 		}
 	}
 
@@ -79,18 +223,8 @@ func (app *application) calculateTotalExpensesAndIncome() (float64, float64) {
 // Filter transactions by excluding or including a search term
 func (app *application) filterTransactions(description string, include bool) Transactions {
 	var filtered Transactions
-	searchTerms := strings.Split(description, "|")
-
 	for _, transaction := range *app.transactions {
-		matched := false
-		lowerDesc := strings.ToLower(transaction.Description)
-		for _, term := range searchTerms {
-			lowerTerm := strings.ToLower(term)
-			if strings.Contains(lowerDesc, lowerTerm) {
-				matched = true
-				break
-			}
-		}
+		matched := matchesAny(transaction.Description, description)
 		if include == matched {
 			filtered = append(filtered, transaction)
 		}
@@ -99,23 +233,25 @@ func (app *application) filterTransactions(description string, include bool) Tra
 	return filtered
 }
 
-// Filter transactions by amount
-func (app *application) filterAmount(amount float64, greater bool) Transactions {
-	var filtered Transactions
-	for _, transaction := range *app.transactions {
-		if (greater && transaction.Amount >= amount) || (!greater && transaction.Amount <= amount) {
-			filtered = append(filtered, transaction)
+// matchesAny reports whether the description contains any of the "|"
+// separated search terms, ignoring case.
+func matchesAny(description, terms string) bool {
+	lowerDesc := strings.ToLower(description)
+	for _, term := range strings.Split(terms, "|") {
+		lowerTerm := strings.ToLower(term)
+		if strings.Contains(lowerDesc, lowerTerm) {
+			return true
 		}
 	}
 
-	return filtered
+	return false
 }
 
-// Filter transactions by date
-func (app *application) filterDate(date time.Time, after bool) Transactions {
+// Filter transactions by amount
+func (app *application) filterAmount(amount float64, greater bool) Transactions {
 	var filtered Transactions
 	for _, transaction := range *app.transactions {
-		if (after && transaction.Date.After(date)) || (!after && transaction.Date.Before(date)) {
+		if (greater && transaction.Amount >= amount) || (!greater && transaction.Amount <= amount) {
 			filtered = append(filtered, transaction)
 		}
 	}
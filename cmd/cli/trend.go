@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"math"
 	"sort"
-	"time"
 )
 
 type Trend struct {
@@ -61,19 +60,19 @@ func (app *application) calculateTopTrends(transactions Transactions, topX int,
 }
 
 // printTopTrendsByDate prints top trends for a given date range
-func (app *application) printTopTrendsByDate(startDate, endDate time.Time, topTrends []Trend, txType TransactionType) {
+func (app *application) printTopTrendsByDate(dateRange DateRange, topTrends []Trend, txType TransactionType) {
 	if len(topTrends) > 0 {
 		if txType == Income {
-			fmt.Printf("Top Incomes Trends for %s to %s:\n", startDate.Format("02-01-2006"), endDate.Format("02-01-2006"))
+			fmt.Printf("Top Incomes Trends for %s:\n", dateRange)
 		} else {
-			fmt.Printf("Top Expenses Trends for %s to %s:\n", startDate.Format("02-01-2006"), endDate.Format("02-01-2006"))
+			fmt.Printf("Top Expenses Trends for %s:\n", dateRange)
 		}
 		var total float64
 		for _, trend := range topTrends {
-			fmt.Printf("  %s: $%.2f\n", trend.Description, trend.TotalAmount)
+			fmt.Printf("  %s: %s\n", trend.Description, app.money(trend.TotalAmount))
 			total += trend.TotalAmount
 		}
-		fmt.Printf("Total: $%.2f\n", total)
+		fmt.Printf("Total: %s\n", app.money(total))
 		fmt.Print("\n")
 	}
 }
@@ -82,17 +81,14 @@ func (app *application) printTopTrendsByDate(startDate, endDate time.Time, topTr
 func (app *application) printTopTrends(topX int, filterByDate bool) {
 	dateRanges := app.getDateRanges(filterByDate)
 
-	for _, dates := range dateRanges {
-		startDate := dates[0]
-		endDate := dates[1]
-
-		filteredTransactions := app.filterByDateRange(startDate, endDate)
+	for _, dateRange := range dateRanges {
+		filteredTransactions := app.filterByDateRange(dateRange)
 
 		var totalIncomes, totalExpenses float64
 		for _, txType := range []TransactionType{Income, Expense} {
 			filteredTypeTransactions := app.filterTransactionsByType(filteredTransactions, txType)
 			topTrends := app.calculateTopTrends(filteredTypeTransactions, topX, txType)
-			app.printTopTrendsByDate(startDate, endDate, topTrends, txType)
+			app.printTopTrendsByDate(dateRange, topTrends, txType)
 
 			if txType == Income {
 				for _, trend := range topTrends {
@@ -106,7 +102,7 @@ func (app *application) printTopTrends(topX int, filterByDate bool) {
 		}
 
 		savingsRate := app.calculateSavingsRate(totalIncomes, totalExpenses)
-		fmt.Printf("Total: $%.2f\n", totalIncomes-totalExpenses)
+		fmt.Printf("Total: %s\n", app.money(totalIncomes-totalExpenses))
 		fmt.Printf("Savings Rate: %.2f%%\n", savingsRate)
 		fmt.Println("--------------------------------------------------")
 	}
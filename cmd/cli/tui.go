@@ -0,0 +1,456 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+type tuiMode int
+
+const (
+	browsing tuiMode = iota
+	filtering
+	categorizing
+	tagging
+	splitting
+)
+
+// prompts are shown on the bottom line while typing in each mode.
+var prompts = map[tuiMode]string{
+	filtering:    "Filter (| separates terms, ! excludes, # matches tags): ",
+	categorizing: "Category: ",
+	tagging:      "Tags (comma separated, toggles each): ",
+	splitting:    "Split off amount and category: ",
+}
+
+// tui is the state of the full-screen transaction browser.
+type tui struct {
+	app *application
+	// all holds every transaction with edits applied, including excluded
+	// ones so they can be brought back.
+	all Transactions
+	// view holds the transactions matching the filter.
+	view    Transactions
+	filter  string
+	cursor  int
+	offset  int
+	mode    tuiMode
+	input   string
+	message string
+	width   int
+	height  int
+}
+
+// refresh reapplies the edits and filter after either changes.
+func (t *tui) refresh() {
+	t.all = applyEdits(t.app.imported, t.app.edits, true)
+	t.app.transactions = &t.all
+
+	switch {
+	case t.filter == "" || t.filter == "!" || t.filter == "#":
+		t.view = t.all
+	case strings.HasPrefix(t.filter, "#"):
+		t.view = filterTags(t.all, t.filter[1:])
+	case strings.HasPrefix(t.filter, "!"):
+		t.view = t.app.filterTransactions(t.filter[1:], false)
+	default:
+		t.view = t.app.filterTransactions(t.filter, true)
+	}
+
+	if t.cursor >= len(t.view) {
+		t.cursor = len(t.view) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+}
+
+// included returns the filtered transactions that count towards totals.
+func (t *tui) included() Transactions {
+	var included Transactions
+	for _, transaction := range t.view {
+		if !transaction.Excluded {
+			included = append(included, transaction)
+		}
+	}
+	return included
+}
+
+// selected returns the transaction under the cursor.
+func (t *tui) selected() (Transaction, bool) {
+	if len(t.view) == 0 {
+		return Transaction{}, false
+	}
+	return t.view[t.cursor], true
+}
+
+// edit changes the edit of the selected transaction and saves every edit.
+func (t *tui) edit(change func(edit *Edit, transaction Transaction) error) {
+	transaction, ok := t.selected()
+	if !ok {
+		return
+	}
+
+	id := splitParent(transaction.ID)
+	edit := t.app.edits[id]
+	if err := change(&edit, transaction); err != nil {
+		t.message = err.Error()
+		return
+	}
+	t.app.edits[id] = edit
+
+	if err := saveEdits(t.app.editsFile, t.app.edits); err != nil {
+		t.message = fmt.Sprintf("Unable to save edits: %s", err)
+		return
+	}
+
+	t.refresh()
+	t.message = "Saved to " + t.app.editsFile
+}
+
+// categorize sets the category of the selected transaction, or of the split
+// it is part of.
+func (t *tui) categorize(category string) {
+	t.edit(func(edit *Edit, transaction Transaction) error {
+		if _, part, ok := strings.Cut(transaction.ID, "/"); ok {
+			index, _ := strconv.Atoi(part)
+			edit.Splits[index-1].Category = category
+			return nil
+		}
+		edit.Category = category
+		return nil
+	})
+}
+
+// tag toggles each of the comma separated tags on the selected transaction.
+func (t *tui) tag(tags string) {
+	t.edit(func(edit *Edit, transaction Transaction) error {
+		for _, tag := range strings.Split(tags, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" {
+				continue
+			}
+
+			if containsTag(edit.Tags, tag) {
+				edit.Tags = removeTags(edit.Tags, tag)
+			} else {
+				edit.Tags = append(edit.Tags, tag)
+			}
+		}
+		return nil
+	})
+}
+
+// split divides an amount and optional category off the selected transaction.
+func (t *tui) split(input string) {
+	t.edit(func(edit *Edit, transaction Transaction) error {
+		fields := strings.Fields(input)
+		if len(fields) == 0 {
+			return errors.New("enter an amount to split off")
+		}
+
+		amount, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || amount == 0 {
+			return fmt.Errorf("invalid amount %q", fields[0])
+		}
+
+		// Split amounts take the sign of the transaction they come from
+		original := t.app.transactionByID(splitParent(transaction.ID))
+		amount = math.Copysign(amount, original.Amount)
+
+		remainder := original.Amount
+		for _, split := range edit.Splits {
+			remainder -= split.Amount
+		}
+		if math.Abs(amount) >= math.Abs(remainder) {
+			return fmt.Errorf("only %.2f is left to split", remainder)
+		}
+
+		edit.Splits = append(edit.Splits, Split{
+			Amount:   amount,
+			Category: strings.Join(fields[1:], " "),
+		})
+		return nil
+	})
+}
+
+// toggleExcluded excludes the selected transaction from totals, or includes
+// it again.
+func (t *tui) toggleExcluded() {
+	t.edit(func(edit *Edit, transaction Transaction) error {
+		edit.Excluded = !edit.Excluded
+		return nil
+	})
+}
+
+// transactionByID returns the imported transaction with the given ID.
+func (app *application) transactionByID(id string) Transaction {
+	for _, transaction := range app.imported {
+		if transaction.ID == id {
+			return transaction
+		}
+	}
+	return Transaction{}
+}
+
+// handleKey acts on one key press and reports whether to quit.
+func (t *tui) handleKey(key string) bool {
+	if key == "\x03" {
+		return true
+	}
+
+	if t.mode != browsing {
+		switch key {
+		case "\r", "\n":
+			input, mode := t.input, t.mode
+			t.mode, t.input = browsing, ""
+			switch mode {
+			case categorizing:
+				t.categorize(strings.TrimSpace(input))
+			case tagging:
+				t.tag(input)
+			case splitting:
+				t.split(input)
+			}
+		case "\x1b":
+			if t.mode == filtering {
+				t.filter = ""
+				t.refresh()
+			}
+			t.mode, t.input = browsing, ""
+		case "\x7f", "\b":
+			if runes := []rune(t.input); len(runes) > 0 {
+				t.input = string(runes[:len(runes)-1])
+			}
+		default:
+			if key[0] >= ' ' && key[0] != 0x7f {
+				t.input += key
+			}
+		}
+
+		// Filters apply as they are typed
+		if t.mode == filtering {
+			t.filter = t.input
+			t.refresh()
+		}
+		return false
+	}
+
+	t.message = ""
+	page := t.height - 4
+	switch key {
+	case "q":
+		return true
+	case "\x1b[A", "k":
+		t.cursor--
+	case "\x1b[B", "j":
+		t.cursor++
+	case "\x1b[5~":
+		t.cursor -= page
+	case "\x1b[6~", " ":
+		t.cursor += page
+	case "g", "\x1b[H", "\x1b[1~":
+		t.cursor = 0
+	case "G", "\x1b[F", "\x1b[4~":
+		t.cursor = len(t.view) - 1
+	case "/":
+		t.mode, t.input = filtering, t.filter
+	case "c":
+		t.mode = categorizing
+	case "t":
+		t.mode = tagging
+	case "s":
+		t.mode = splitting
+	case "x":
+		t.toggleExcluded()
+	}
+
+	if t.cursor >= len(t.view) {
+		t.cursor = len(t.view) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+
+	return false
+}
+
+// sidePanel returns the totals and top trends of the filtered transactions.
+func (t *tui) sidePanel() []string {
+	included := t.included()
+	if len(included) == 0 {
+		return []string{"No transactions"}
+	}
+
+	all := t.app.transactions
+	t.app.transactions = &included
+	defer func() { t.app.transactions = all }()
+
+	totalExpenses, totalIncome := t.app.calculateTotalExpensesAndIncome()
+	lines := []string{
+		"Totals",
+		fmt.Sprintf("  Expenses  %12.2f", totalExpenses),
+		fmt.Sprintf("  Income    %12.2f", totalIncome),
+		fmt.Sprintf("  Total     %12.2f", totalIncome-totalExpenses),
+	}
+	if totalIncome > 0 {
+		lines = append(lines, fmt.Sprintf("  Savings   %11.2f%%", t.app.calculateSavingsRate(totalIncome, totalExpenses)))
+	}
+
+	for _, txType := range []TransactionType{Expense, Income} {
+		trends := t.app.calculateTopTrends(included, 5, txType)
+		if len(trends) == 0 {
+			continue
+		}
+
+		if txType == Income {
+			lines = append(lines, "", "Top Incomes")
+		} else {
+			lines = append(lines, "", "Top Expenses")
+		}
+		for _, trend := range trends {
+			lines = append(lines, fmt.Sprintf("  %s %10.2f", fitLabel(trend.Description, 18), trend.TotalAmount))
+		}
+	}
+
+	return lines
+}
+
+// render draws the whole screen.
+func (t *tui) render() string {
+	if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		t.width, t.height = width, height
+	}
+
+	panelWidth := 34
+	if t.width < 90 {
+		panelWidth = 0
+	}
+	tableWidth := t.width - panelWidth
+	rows := t.height - 3
+
+	// Keep the cursor on screen
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+rows-1 {
+		t.offset = t.cursor - rows + 2
+	}
+
+	var screen strings.Builder
+	screen.WriteString("\x1b[H")
+
+	header := fmt.Sprintf(" FineAnts  %d of %d transactions", len(t.view), len(t.all))
+	if t.filter != "" {
+		header += "  Filter: " + t.filter
+	}
+	screen.WriteString("\x1b[1m" + fitLabel(header, t.width) + "\x1b[0m\r\n")
+
+	var panel []string
+	if panelWidth > 0 {
+		panel = t.sidePanel()
+	}
+
+	descriptionWidth := tableWidth - 10 - 12 - 16 - 16 - 6
+	if descriptionWidth < 10 {
+		descriptionWidth = 10
+	}
+
+	for row := 0; row < rows; row++ {
+		var line string
+		if row == 0 {
+			line = fmt.Sprintf(" %-10s %11s  %s  %s  %s", "Date", "Amount", fitLabel("Category", 14), fitLabel("Tags", 14), "Description")
+		} else if index := t.offset + row - 1; index < len(t.view) {
+			transaction := t.view[index]
+			mark := " "
+			if transaction.Excluded {
+				mark = "x"
+			}
+			line = fmt.Sprintf("%s%-10s %11.2f  %s  %s  %s", mark,
+				transaction.Date.Format("02-01-2006"), transaction.Amount,
+				fitLabel(transaction.Category, 14), fitLabel(strings.Join(transaction.Tags, ","), 14),
+				fitLabel(transaction.Description, descriptionWidth))
+
+			line = fitLabel(line, tableWidth)
+			switch {
+			case index == t.cursor:
+				line = "\x1b[7m" + line + "\x1b[0m"
+			case transaction.Excluded:
+				line = "\x1b[2m" + line + "\x1b[0m"
+			}
+		}
+		if !strings.HasPrefix(line, "\x1b") {
+			line = fitLabel(line, tableWidth)
+		}
+		screen.WriteString(line)
+
+		if panelWidth > 0 {
+			panelLine := ""
+			if row < len(panel) {
+				panelLine = panel[row]
+			}
+			screen.WriteString("│ " + fitLabel(panelLine, panelWidth-2))
+		}
+		screen.WriteString("\r\n")
+	}
+
+	help := " ↑↓ move  / filter  c category  t tag  s split  x exclude  q quit"
+	screen.WriteString("\x1b[7m" + fitLabel(help, t.width) + "\x1b[0m\r\n")
+
+	if t.mode != browsing {
+		screen.WriteString(fitLabel(prompts[t.mode]+t.input, t.width) + "\x1b[?25h")
+	} else {
+		screen.WriteString(fitLabel(t.message, t.width) + "\x1b[?25l")
+	}
+
+	return screen.String()
+}
+
+// tuiCommand opens a full-screen browser for categorizing, tagging, splitting
+// and excluding transactions.
+func (app *application) tuiCommand(args []string) error {
+	fs, src := newFlagSet("tui")
+	fs.Parse(args)
+
+	src.keepExcluded = true
+	if err := app.load(src); err != nil {
+		return err
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("tui needs to be run in a terminal")
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	// Switch to the alternate screen and back again when done
+	fmt.Print("\x1b[?1049h")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	t := &tui{app: app, width: 80, height: 24}
+	t.refresh()
+
+	buf := make([]byte, 64)
+	for {
+		fmt.Print(t.render())
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+		if t.handleKey(string(buf[:n])) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Scenario is a set of changes to spending and income to try out against the
+// ledger.
+type Scenario struct {
+	Description string `yaml:"description"`
+	// Scale changes spending in categories by a percentage, so -30 cuts it
+	// by 30%.
+	Scale []CategoryScale `yaml:"scale"`
+	// Remove drops payees matching these "|" separated terms, as with -ex.
+	Remove string `yaml:"remove"`
+	// Add adds recurring income or expenses.
+	Add []RecurringItem `yaml:"add"`
+	// Income changes all income by a percentage.
+	Income float64 `yaml:"income"`
+}
+
+// CategoryScale changes the spending in a category by a percentage.
+type CategoryScale struct {
+	Category string  `yaml:"category"`
+	Percent  float64 `yaml:"percent"`
+}
+
+// RecurringItem is an amount paid or received regularly, negative for
+// expenses.
+type RecurringItem struct {
+	Description string  `yaml:"description"`
+	Amount      float64 `yaml:"amount"`
+	Category    string  `yaml:"category"`
+	// Every is week, fortnight, month, quarter or year.
+	Every string `yaml:"every"`
+}
+
+// recurrence returns the date after t an item that recurs every interval is
+// due.
+func recurrence(t time.Time, every string) (time.Time, error) {
+	switch every {
+	case "week":
+		return t.AddDate(0, 0, 7), nil
+	case "fortnight":
+		return t.AddDate(0, 0, 14), nil
+	case "month", "":
+		return t.AddDate(0, 1, 0), nil
+	case "quarter":
+		return t.AddDate(0, 3, 0), nil
+	case "year":
+		return t.AddDate(1, 0, 0), nil
+	}
+	return t, fmt.Errorf("unknown interval %q: use week, fortnight, month, quarter or year", every)
+}
+
+// apply returns the transactions with the scenario's changes made, and its
+// recurring items added across the span.
+func (s Scenario) apply(transactions Transactions, span DateRange) (Transactions, error) {
+	var changed Transactions
+	for _, transaction := range transactions {
+		if s.Remove != "" && matchesAny(transaction.Description, s.Remove) {
+			continue
+		}
+
+		factor := 1.0
+		if transaction.Type == Income {
+			factor += s.Income / 100
+		} else {
+			for _, scale := range s.Scale {
+				if strings.EqualFold(transaction.Category, scale.Category) {
+					factor += scale.Percent / 100
+				}
+			}
+		}
+		transaction.Amount *= math.Max(factor, 0)
+		transaction.Reimbursed *= math.Max(factor, 0)
+		transaction.Reimbursement *= math.Max(factor, 0)
+		changed = append(changed, transaction)
+	}
+
+	for _, item := range s.Add {
+		if item.Amount == 0 {
+			return nil, fmt.Errorf("recurring item %q needs an amount", item.Description)
+		}
+		for date := span.Start; span.Contains(date); {
+			changed = append(changed, Transaction{
+				Date:        date,
+				Amount:      item.Amount,
+				Description: item.Description,
+				Type:        transactionType(item.Amount),
+				Category:    item.Category,
+			})
+
+			next, err := recurrence(date, strings.ToLower(item.Every))
+			if err != nil {
+				return nil, fmt.Errorf("recurring item %q: %w", item.Description, err)
+			}
+			date = next
+		}
+	}
+
+	return changed, nil
+}
+
+// totalsFor returns the total expenses and income of some transactions.
+func (app *application) totalsFor(transactions Transactions) (float64, float64) {
+	original := app.transactions
+	app.transactions = &transactions
+	defer func() { app.transactions = original }()
+	return app.calculateTotalExpensesAndIncome()
+}
+
+// categoryExpenses totals expenses by category.
+func categoryExpenses(transactions Transactions) map[string]float64 {
+	totals := make(map[string]float64)
+	for _, transaction := range transactions {
+		if transaction.Type != Expense || transaction.Settlement != nil {
+			continue
+		}
+		category := transaction.Category
+		if category == "" {
+			category = "Uncategorized"
+		}
+		totals[category] += -transaction.Amount - transaction.Reimbursed
+	}
+	return totals
+}
+
+// printScenario compares the baseline and scenario totals, the categories
+// the scenario changes, and when each goal is expected to be met. Totals are
+// multiplied by scale, to turn the history into a forecast.
+func (app *application) printScenario(baseline, scenario, all Transactions, scale float64) error {
+	baseExpenses, baseIncome := app.totalsFor(baseline)
+	newExpenses, newIncome := app.totalsFor(scenario)
+
+	fmt.Printf("%-16s  %14s  %14s  %14s\n", "", "Baseline", "Scenario", "Change")
+	for _, row := range []struct {
+		label         string
+		before, after float64
+	}{
+		{"Expenses", baseExpenses * scale, newExpenses * scale},
+		{"Income", baseIncome * scale, newIncome * scale},
+		{"Saved", (baseIncome - baseExpenses) * scale, (newIncome - newExpenses) * scale},
+	} {
+		fmt.Printf("%-16s  %14.2f  %14.2f  %14.2f\n", row.label, row.before, row.after, row.after-row.before)
+	}
+
+	// Without income there is no rate to save at
+	rates := []string{"n/a", "n/a", "n/a"}
+	if baseIncome > 0 {
+		rates[0] = fmt.Sprintf("%.2f%%", app.calculateSavingsRate(baseIncome, baseExpenses))
+	}
+	if newIncome > 0 {
+		rates[1] = fmt.Sprintf("%.2f%%", app.calculateSavingsRate(newIncome, newExpenses))
+	}
+	if baseIncome > 0 && newIncome > 0 {
+		rates[2] = fmt.Sprintf("%.2f%%", app.calculateSavingsRate(newIncome, newExpenses)-app.calculateSavingsRate(baseIncome, baseExpenses))
+	}
+	fmt.Printf("%-16s  %14s  %14s  %14s\n", "Savings rate", rates[0], rates[1], rates[2])
+
+	before, after := categoryExpenses(baseline), categoryExpenses(scenario)
+	var categories []string
+	for category := range after {
+		if math.Abs(after[category]-before[category]) >= 0.005 {
+			categories = append(categories, category)
+		}
+	}
+	for category := range before {
+		if _, ok := after[category]; !ok {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	if len(categories) > 0 {
+		fmt.Println()
+		fmt.Printf("%-16s  %14s  %14s  %14s\n", "Category", "Baseline", "Scenario", "Change")
+		for _, category := range categories {
+			fmt.Printf("%-16s  %14.2f  %14.2f  %14.2f\n", fitLabel(category, 16),
+				before[category]*scale, after[category]*scale, (after[category]-before[category])*scale)
+		}
+	}
+
+	if len(app.config.Goals) == 0 {
+		return nil
+	}
+
+	today := localDate(now(), app.location())
+	baseMonthly, _ := app.monthlySavings(baseline)
+	newMonthly, _ := app.monthlySavings(scenario)
+	baseGoals, err := app.calculateGoals(app.config.Goals, all, baseMonthly, today)
+	if err != nil {
+		return err
+	}
+	newGoals, err := app.calculateGoals(app.config.Goals, all, newMonthly, today)
+	if err != nil {
+		return err
+	}
+
+	projected := func(p GoalProgress) string {
+		label := "never"
+		if !p.Projected.IsZero() {
+			label = p.Projected.Format("02-01-2006")
+		}
+		if p.AtRisk() {
+			label += " at risk"
+		}
+		return label
+	}
+	fmt.Println()
+	fmt.Printf("%-16s  %-10s  %-18s  %s\n", "Goal", "Deadline", "Baseline", "Scenario")
+	for i := range baseGoals {
+		fmt.Printf("%-16s  %-10s  %-18s  %s\n", fitLabel(baseGoals[i].Name, 16),
+			baseGoals[i].Deadline.Format("02-01-2006"), projected(baseGoals[i]), projected(newGoals[i]))
+	}
+	return nil
+}
+
+// whatifCommand compares a scenario from the config with what actually
+// happened over a period, or with a forecast built from it.
+func (app *application) whatifCommand(args []string) error {
+	usage := errors.New("usage: whatif <scenario> [filters] [-forecast <months>]")
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return usage
+	}
+	name := args[0]
+
+	fs, src := newFlagSet("whatif " + name)
+	filters := addFilterFlags(fs)
+	forecast := fs.Int("forecast", 0, "forecast this many months ahead at the filtered period's averages,\ninstead of replaying the period")
+	fs.Parse(args[1:])
+
+	scenario, ok := app.config.Scenarios[name]
+	if !ok {
+		return fmt.Errorf("no scenario named %q in the config", name)
+	}
+
+	if err := app.load(src); err != nil {
+		return err
+	}
+	all := *app.transactions
+	if err := app.applyFilters(filters); err != nil {
+		return err
+	}
+	if len(*app.transactions) == 0 {
+		return errors.New("no transactions found")
+	}
+
+	baseline := *app.transactions
+	span := transactionsRange(baseline)
+	changed, err := scenario.apply(baseline, span)
+	if err != nil {
+		return err
+	}
+
+	scale := 1.0
+	if scenario.Description != "" {
+		fmt.Println(scenario.Description)
+	}
+	if *forecast > 0 {
+		months := math.Max((span.End.Sub(span.Start).Hours()/24+1)/daysPerMonth, 1)
+		scale = float64(*forecast) / months
+		fmt.Printf("Forecast of the next %d months at the averages of %s\n\n", *forecast, span)
+	} else {
+		fmt.Printf("Replaying %s\n\n", span)
+	}
+
+	return app.printScenario(baseline, changed, all, scale)
+}
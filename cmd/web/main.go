@@ -1,13 +1,17 @@
 package main
 
 import (
-	"database/sql"
+	"flag"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/isuQuo/FineAnts/internal/database"
+	"github.com/isuQuo/FineAnts/internal/models"
+
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
 )
@@ -22,19 +26,34 @@ type application struct {
 	formDecoder *form.Decoder
 	// sessionManager is used to manage user sessions.
 	sessionManager *scs.SessionManager
+	// users is used to create and authenticate users.
+	users *models.UserModel
 }
 
 func main() {
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
-	// Can be passed as a flag
-	db, err := openDB("PLACE DSN HERE")
+	// The database can be chosen with flags, or with environment variables
+	// when the flags aren't given.
+	driver := flag.String("driver", envOrDefault("FINEANTS_DB_DRIVER", "sqlite"), "Database driver: "+strings.Join(database.Drivers(), " or "))
+	dsn := flag.String("dsn", envOrDefault("FINEANTS_DB_DSN", "fineants.db"), "Database data source name")
+	flag.Parse()
+
+	db, err := database.Open(*driver, *dsn)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 	defer db.Close()
 
+	migrations, err := database.Migrate(db, *driver)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	for _, migration := range migrations {
+		infoLog.Printf("Applied migration %s", migration)
+	}
+
 	templateCache, err := newTemplateCache()
 	if err != nil {
 		errorLog.Fatal(err)
@@ -42,10 +61,13 @@ func main() {
 
 	formDecoder := form.NewDecoder()
 
-	// Create a new session manager
+	// Create a new session manager, storing sessions in the database so they
+	// survive a restart.
+	sessionStore := database.NewSessionStore(db, 5*time.Minute)
+	defer sessionStore.StopCleanup()
+
 	sessionManager := scs.New()
-	// TODO: Set the session store to use your database
-	//sessionManager.Store = mysqlstore.New(db)
+	sessionManager.Store = sessionStore
 	sessionManager.Lifetime = 12 * time.Hour
 
 	app := &application{
@@ -54,6 +76,7 @@ func main() {
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		users:          &models.UserModel{DB: db},
 	}
 
 	// Create a TLS config struct
@@ -77,17 +100,11 @@ func main() {
 	errorLog.Fatal(err)
 }
 
-func openDB(dsn string) (*sql.DB, error) {
-	// Open a new connection to the database.
-	// TODO: Change the driver name to match the database you are using.
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := db.Ping(); err != nil {
-		return nil, err
+// envOrDefault returns the value of an environment variable, or fallback
+// when it isn't set.
+func envOrDefault(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
 	}
-
-	return db, nil
+	return fallback
 }
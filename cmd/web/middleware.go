@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -87,8 +88,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		// TODO: Check if user exists in database
-		/* exists, err := app.users.Exists(id)
+		exists, err := app.users.Exists(id)
 		if err != nil {
 			app.serverError(w, err)
 			return
@@ -97,7 +97,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		if exists {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
 			r = r.WithContext(ctx)
-		} */
+		}
 
 		next.ServeHTTP(w, r)
 	})
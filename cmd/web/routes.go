@@ -25,11 +25,15 @@ func (app *application) routes() http.Handler {
 	)
 
 	// Register the relevant methods, URLs and handlers for the dynamic routes.
-	//router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.signupUserForm))
+	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.signupUserForm))
+	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.signupUserPost))
+	router.Handler(http.MethodGet, "/user/signin", dynamic.ThenFunc(app.signinUserForm))
+	router.Handler(http.MethodPost, "/user/signin", dynamic.ThenFunc(app.signinUserPost))
 
 	protected := dynamic.Append(app.requireAuthentication)
 
 	router.Handler(http.MethodGet, "/", protected.ThenFunc(app.home))
+	router.Handler(http.MethodPost, "/user/signout", protected.ThenFunc(app.signoutUserPost))
 
 	// Create a middleware chain containing our 'standard' middleware
 	// which will be used by every request our application receives.
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	// Register the database drivers that can be chosen with -driver.
+	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
+)
+
+// migrations holds the schema changes for each driver, applied in file name
+// order.
+//
+//go:embed migrations/*/*.sql
+var migrations embed.FS
+
+// Drivers returns the names of the supported database drivers.
+func Drivers() []string {
+	entries, _ := fs.ReadDir(migrations, "migrations")
+
+	var drivers []string
+	for _, entry := range entries {
+		drivers = append(drivers, entry.Name())
+	}
+
+	return drivers
+}
+
+// Open opens a connection pool to the database and checks it can be reached.
+func Open(driver, dsn string) (*sql.DB, error) {
+	if _, err := fs.Stat(migrations, path.Join("migrations", driver)); err != nil {
+		return nil, fmt.Errorf("unsupported database driver %q: use one of %s", driver, strings.Join(Drivers(), ", "))
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer at a time, so share one connection rather
+	// than have concurrent requests fail with "database is locked".
+	if driver == "sqlite" {
+		db.SetMaxOpenConns(1)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Migrate applies the driver's migrations that haven't been applied yet,
+// recording each one in the schema_migrations table. It returns the names of
+// the migrations applied.
+func Migrate(db *sql.DB, driver string) ([]string, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	version VARCHAR(255) NOT NULL PRIMARY KEY
+)`)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fs.Glob(migrations, path.Join("migrations", driver, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var applied []string
+	for _, file := range files {
+		version := strings.TrimSuffix(path.Base(file), ".sql")
+
+		var exists bool
+		err := db.QueryRow("SELECT EXISTS(SELECT true FROM schema_migrations WHERE version = ?)", version).Scan(&exists)
+		if err != nil {
+			return applied, err
+		}
+		if exists {
+			continue
+		}
+
+		if err := migrate(db, file, version); err != nil {
+			return applied, fmt.Errorf("migration %s: %w", version, err)
+		}
+		applied = append(applied, version)
+	}
+
+	return applied, nil
+}
+
+// migrate runs the statements in a migration file and records its version.
+// Statements are separated by semicolons, since not every driver accepts
+// several in one Exec.
+func migrate(db *sql.DB, file, version string) error {
+	contents, err := migrations.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	// MySQL commits DDL statements implicitly, so the transaction only keeps
+	// the migration atomic on databases that support transactional DDL.
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range strings.Split(string(contents), ";") {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES(?)", version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// SessionStore is an scs session store backed by the sessions table. Expiry
+// times are stored as Unix nanoseconds, so they compare the same way on
+// every database.
+type SessionStore struct {
+	db          *sql.DB
+	stopCleanup chan bool
+}
+
+// NewSessionStore returns a session store using the database. Expired
+// sessions are deleted every cleanupInterval, or never if it is 0.
+func NewSessionStore(db *sql.DB, cleanupInterval time.Duration) *SessionStore {
+	s := &SessionStore{db: db}
+
+	if cleanupInterval > 0 {
+		s.stopCleanup = make(chan bool)
+		go s.startCleanup(cleanupInterval)
+	}
+
+	return s
+}
+
+// Find returns the data for a session token. found is false if the token
+// doesn't exist or has expired.
+func (s *SessionStore) Find(token string) (b []byte, found bool, err error) {
+	stmt := "SELECT data FROM sessions WHERE token = ? AND expiry > ?"
+
+	err = s.db.QueryRow(stmt, token, time.Now().UnixNano()).Scan(&b)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+// Commit adds or replaces the data for a session token.
+func (s *SessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	// Replacing the row in a transaction works on every database, where an
+	// upsert would need each one's own syntax.
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM sessions WHERE token = ?", token); err != nil {
+		return err
+	}
+
+	stmt := "INSERT INTO sessions (token, data, expiry) VALUES(?, ?, ?)"
+	if _, err := tx.Exec(stmt, token, b, expiry.UnixNano()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a session token and its data.
+func (s *SessionStore) Delete(token string) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE token = ?", token)
+	return err
+}
+
+// StopCleanup stops the goroutine deleting expired sessions.
+func (s *SessionStore) StopCleanup() {
+	if s.stopCleanup != nil {
+		s.stopCleanup <- true
+	}
+}
+
+func (s *SessionStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.deleteExpired(); err != nil {
+				log.Println(err)
+			}
+		case <-s.stopCleanup:
+			return
+		}
+	}
+}
+
+func (s *SessionStore) deleteExpired() error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE expiry < ?", time.Now().UnixNano())
+	return err
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"errors"
+)
+
+var (
+	// ErrNoRecord is returned when a record can't be found.
+	ErrNoRecord = errors.New("models: no matching record found")
+
+	// ErrInvalidCredentials is returned when a user tries to login with an
+	// incorrect email address or password.
+	ErrInvalidCredentials = errors.New("models: invalid credentials")
+
+	// ErrDuplicateEmail is returned when a user tries to signup with an email
+	// address that's already in use.
+	ErrDuplicateEmail = errors.New("models: duplicate email")
+)
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User holds the data for an individual user. The password is only ever
+// stored as a bcrypt hash.
+type User struct {
+	ID             string
+	Name           string
+	Email          string
+	HashedPassword []byte
+	Created        time.Time
+}
+
+// UserModel wraps a database connection pool holding the users table, with
+// id, name, email (unique), hashed_password and created columns.
+type UserModel struct {
+	DB *sql.DB
+}
+
+// newID returns a random 128-bit user ID, hex encoded, so IDs don't depend on
+// the database's auto increment support.
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Insert adds a new user to the database, hashing their password.
+func (m *UserModel) Insert(name, email, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO users (id, name, email, hashed_password, created)
+	VALUES(?, ?, ?, ?, ?)`
+
+	_, err = m.DB.Exec(stmt, id, name, email, string(hashedPassword), time.Now().UTC())
+	if err != nil {
+		// The error for breaking the UNIQUE constraint on email differs
+		// between database drivers, so check for the email once the insert
+		// has failed. Checking first would race with a concurrent signup.
+		var exists bool
+		if checkErr := m.DB.QueryRow("SELECT EXISTS(SELECT true FROM users WHERE email = ?)", email).Scan(&exists); checkErr == nil && exists {
+			return ErrDuplicateEmail
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Authenticate checks whether a user with the email and password exists,
+// returning their ID if they do.
+func (m *UserModel) Authenticate(email, password string) (string, error) {
+	var id string
+	var hashedPassword []byte
+
+	stmt := "SELECT id, hashed_password FROM users WHERE email = ?"
+
+	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrInvalidCredentials
+		}
+		return "", err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return "", ErrInvalidCredentials
+		}
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Exists reports whether a user with the ID exists.
+func (m *UserModel) Exists(id string) (bool, error) {
+	var exists bool
+
+	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
+
+	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	return exists, err
+}